@@ -3,7 +3,7 @@
 
 provider "registry.opentofu.org/hashicorp/google" {
   version     = "7.10.0"
-  constraints = ">= 6.0.0"
+  constraints = ">= 7.10.0"
   hashes = [
     "h1:jbiJEr0O4XQpXjy6ydZGL1hFx0+UfgSVzInYW0rcWg8=",
     "zh:07038f7f9b4e417675c7ac9fd0a084845215f14c0e4a073934666ea0f6511333",
@@ -20,7 +20,7 @@ provider "registry.opentofu.org/hashicorp/google" {
 
 provider "registry.opentofu.org/hashicorp/local" {
   version     = "2.5.3"
-  constraints = ">= 2.0.0"
+  constraints = ">= 2.2.0"
   hashes = [
     "h1:mC9+u1eaUILTjxey6Ivyf/3djm//RNNze9kBVX/trng=",
     "zh:32e1d4b0595cea6cda4ca256195c162772ddff25594ab4008731a2ec7be230bf",
@@ -20,7 +20,7 @@ terraform {
   required_providers {
     google = {
       source  = "hashicorp/google"
-      version = "~> 6.0"
+      version = "~> 7.10"
     }
   }
 }
@@ -86,10 +86,12 @@ module "postgres_dev" {
   ]
 
   # Development-appropriate settings
-  availability_type     = "ZONAL" # Single zone (cheaper for dev)
-  backup_enabled        = true    # Enable backups
-  deletion_protection   = false   # Allow easy cleanup for dev
-  backup_retention_days = 7       # Keep 7 days of backups (vs 30 for prod)
+  availability_type      = "ZONAL" # Single zone (cheaper for dev)
+  backup_enabled         = true    # Enable backups
+  deletion_protection    = false   # Allow easy cleanup for dev
+  backup_retention_count = 7       # Keep 7 daily backups (vs 30 for prod)
+
+  transaction_log_retention_days = 3 # Must be less than the retained backups
 
   # Monitoring and performance
   query_insights_enabled          = true
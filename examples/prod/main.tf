@@ -8,6 +8,7 @@
  * - Performance preset (8 vCPUs, 32GB RAM, 1TB disk, ENTERPRISE_PLUS)
  * - Regional high availability for automatic failover
  * - Read replicas for load distribution and disaster recovery
+ * - Enterprise Plus DR replica with variable-driven switchover
  * - Restricted network access to specific CIDR ranges
  * - SSL/TLS encryption enforcement
  * - Comprehensive backup and point-in-time recovery
@@ -23,7 +24,7 @@ terraform {
   required_providers {
     google = {
       source  = "hashicorp/google"
-      version = "~> 6.0"
+      version = "~> 7.10"
     }
   }
 }
@@ -100,7 +101,7 @@ module "postgres_prod" {
   backup_enabled                 = true
   backup_start_time              = "02:00"    # 2 AM daily backups
   backup_location                = var.region # Store in same region
-  backup_retention_days          = 30         # Keep 30 days of backups
+  backup_retention_count         = 30         # Keep 30 daily backups
   point_in_time_recovery         = true       # Enable PITR
   transaction_log_retention_days = 7          # 7 days for PITR
 
@@ -111,9 +112,13 @@ module "postgres_prod" {
       region            = var.replica_region # Different region for DR
       availability_type = "ZONAL"            # ZONAL is sufficient for read replica
       failover_target   = false              # Not a failover target (use for reads only)
+      disaster_recovery = true               # Enterprise Plus DR replica for cross-region switchover
     }
   }
 
+  # Set to true to switch over to the DR replica, and back to false to switch back
+  dr_switchover = false
+
   # Query Insights and Monitoring
   query_insights_enabled  = true
   query_string_length     = 2048 # Capture longer queries
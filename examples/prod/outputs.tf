@@ -28,6 +28,11 @@ output "read_replicas" {
   value       = module.postgres_prod.read_replicas
 }
 
+output "replication_cluster" {
+  description = "Disaster recovery roles, including which instance currently holds the primary role"
+  value       = module.postgres_prod.replication_cluster
+}
+
 output "cloud_sql_proxy_command" {
   description = "Command to connect via Cloud SQL proxy"
   value       = module.postgres_prod.cloud_sql_proxy_command
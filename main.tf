@@ -9,8 +9,16 @@
  * - Configurable user roles (admin, read-write, read-only, custom)
  * - Preset configurations (budget, balanced, performance)
  * - Automatic password generation and Secret Manager integration
+ * - Clone and point-in-time restore creation mode
  * - PostgreSQL-specific performance tuning
- * - Read replica configuration
+ * - Connection pooling (managed or generated PgBouncer configuration)
+ * - Read replica configuration, including cascading replicas
+ * - Enterprise Plus read pools
+ * - Scheduled logical exports to Cloud Storage
+ * - Backup and DR vault protection
+ * - Continuous migration from external PostgreSQL through Database Migration Service
+ * - Permission model output for managing roles, grants and extensions with the postgresql provider (modules/postgresql-native)
+ * - pg_cron job management
  * - Performance monitoring with pg_stat_statements
  */
 
@@ -22,6 +30,25 @@ resource "random_id" "instance_suffix" {
 locals {
   instance_name = var.use_random_suffix ? "${var.instance_name}-${random_id.instance_suffix.hex}" : var.instance_name
 
+  # Deletion safeguards default to safe values in production
+  is_production               = var.environment == "production"
+  deletion_protection         = coalesce(var.deletion_protection, local.is_production)
+  deletion_protection_enabled = coalesce(var.deletion_protection_enabled, local.is_production)
+  final_backup_enabled        = coalesce(var.final_backup.enabled, local.is_production)
+  retain_backups_on_delete    = coalesce(var.retain_backups_on_delete, local.is_production)
+
+  # Transaction log retention limits for point-in-time recovery per edition
+  max_transaction_log_retention_days = local.final_edition == "ENTERPRISE_PLUS" ? 35 : 7
+
+  # Automated backups to keep; Cloud SQL takes one per day, so a number of days is the same number of backups
+  retained_backups = coalesce(var.backup_retention_count, var.backup_retention_days, 30)
+
+  # Major PostgreSQL version, for statements that differ between versions
+  postgres_major_version = tonumber(trimprefix(var.postgres_version, "POSTGRES_"))
+
+  # How the instance is created: from scratch, cloned from another instance, or restored from a backup run
+  clone_mode = var.clone_from == null ? "none" : (var.clone_from.backup_run_id != null ? "backup" : "clone")
+
   # Determine actual configuration based on presets or custom values
   # When using presets, use preset values; when custom, use provided values (with fallback to balanced preset if null)
   final_machine_type = var.use_preset_config != "custom" ? var.config_presets[var.use_preset_config].machine_type : coalesce(var.machine_type, var.config_presets["balanced"].machine_type)
@@ -41,9 +68,39 @@ resource "google_sql_database_instance" "postgres" {
   name                = local.instance_name
   database_version    = var.postgres_version
   region              = var.region
-  deletion_protection = var.deletion_protection
+  deletion_protection = local.deletion_protection
   project             = var.project_id
 
+  # Role swapping for Enterprise Plus switchover to the DR replica. The switchover itself is driven by the DR
+  # replica; instance_type and master_instance_name of this instance follow Cloud SQL (see the lifecycle below).
+  replica_names = local.dr_switchover_active ? [] : null
+
+  dynamic "replication_cluster" {
+    for_each = local.dr_replica_key != null && !local.dr_switchover_active ? [1] : []
+    content {
+      failover_dr_replica_name = "${var.project_id}:${local.dr_replica_instance_name}"
+    }
+  }
+
+  # Clone mode: copy an existing instance at a point in time, or restore one of its backup runs
+  dynamic "clone" {
+    for_each = local.clone_mode == "clone" ? [var.clone_from] : []
+    content {
+      source_instance_name = clone.value.source_instance_name
+      point_in_time        = clone.value.point_in_time
+      preferred_zone       = clone.value.preferred_zone
+    }
+  }
+
+  dynamic "restore_backup_context" {
+    for_each = local.clone_mode == "backup" ? [var.clone_from] : []
+    content {
+      backup_run_id = restore_backup_context.value.backup_run_id
+      instance_id   = restore_backup_context.value.source_instance_name
+      project       = coalesce(restore_backup_context.value.source_project, var.project_id)
+    }
+  }
+
   settings {
     tier      = local.final_machine_type
     edition   = local.final_edition
@@ -55,15 +112,25 @@ resource "google_sql_database_instance" "postgres" {
 
     availability_type = var.availability_type
 
+    # Protection against losing data when the instance is deleted
+    deletion_protection_enabled = local.deletion_protection_enabled
+    retain_backups_on_delete    = local.retain_backups_on_delete
+
+    final_backup_config {
+      enabled        = local.final_backup_enabled
+      retention_days = local.final_backup_enabled ? var.final_backup.retention_days : null
+    }
+
+    # Backups move to the DR replica while it holds the primary role
     backup_configuration {
-      enabled                        = var.backup_enabled
+      enabled                        = var.backup_enabled && !local.dr_switchover_active
       start_time                     = var.backup_start_time
       location                       = var.backup_location
-      point_in_time_recovery_enabled = var.point_in_time_recovery
+      point_in_time_recovery_enabled = var.point_in_time_recovery && !local.dr_switchover_active
       transaction_log_retention_days = var.transaction_log_retention_days
 
       backup_retention_settings {
-        retained_backups = var.backup_retention_days
+        retained_backups = local.retained_backups
         retention_unit   = "COUNT"
       }
     }
@@ -108,6 +175,9 @@ resource "google_sql_database_instance" "postgres" {
     dynamic "database_flags" {
       for_each = merge(
         local.postgres_performance_flags,
+        local.migration_flags,
+        local.extension_database_flags,
+        local.cron_flags,
         var.additional_database_flags
       )
       content {
@@ -125,6 +195,22 @@ resource "google_sql_database_instance" "postgres" {
       }
     )
 
+    # Managed connection pooling (Enterprise Plus)
+    dynamic "connection_pool_config" {
+      for_each = local.managed_connection_pooling ? [1] : []
+      content {
+        connection_pooling_enabled = true
+
+        dynamic "flags" {
+          for_each = local.managed_connection_pool_flags
+          content {
+            name  = flags.key
+            value = flags.value
+          }
+        }
+      }
+    }
+
     # Advanced configurations
     pricing_plan          = var.pricing_plan
     connector_enforcement = var.connector_enforcement
@@ -144,6 +230,66 @@ resource "google_sql_database_instance" "postgres" {
     update = var.timeouts.update
     delete = var.timeouts.delete
   }
+
+  lifecycle {
+    # Cloud SQL demotes this instance to a replica of the DR replica at a switchover, and Database Migration
+    # Service demotes it to a replica of the migration source until the job is promoted
+    ignore_changes = [instance_type, master_instance_name, replica_configuration]
+
+    precondition {
+      condition     = !var.dr_switchover || local.dr_replica_key != null
+      error_message = "dr_switchover requires a read replica with disaster_recovery = true."
+    }
+
+    precondition {
+      condition     = var.transaction_log_retention_days >= 1 && var.transaction_log_retention_days <= local.max_transaction_log_retention_days
+      error_message = "transaction_log_retention_days must be between 1 and 7 for ENTERPRISE and between 1 and 35 for ENTERPRISE_PLUS."
+    }
+
+    precondition {
+      condition     = !var.backup_enabled || var.transaction_log_retention_days < local.retained_backups
+      error_message = "transaction_log_retention_days must be less than the number of retained backups (${local.retained_backups})."
+    }
+
+    precondition {
+      condition     = var.backup_retention_count == null || var.backup_retention_days == null
+      error_message = "Set backup_retention_count or backup_retention_days, not both."
+    }
+
+    # Checked on the instance so that databases copied from a clone source are covered as well
+    precondition {
+      condition = alltrue(flatten([
+        for db in values(var.databases) : [
+          for owner in concat([db.owner], [for schema in values(db.schemas) : schema.owner]) :
+          owner == null ? true : contains(keys(var.users), owner)
+        ]
+      ]))
+      error_message = "Owners of databases and their schemas must be keys in var.users."
+    }
+
+    precondition {
+      condition = (
+        length(distinct(local.permission_group_names)) == length(local.permission_group_names) &&
+        length(setintersection(local.permission_group_names, setunion(keys(var.users), keys(var.deprovisioned_users)))) == 0
+      )
+      error_message = "Group role names (<database>_<level> and <database>_<schema>_<level>) must be unique and must not match a user name; rename the database, schema or user."
+    }
+
+    precondition {
+      condition     = length(local.unsupported_extensions) == 0
+      error_message = "Extensions not in the Cloud SQL allowlist: ${join(", ", local.unsupported_extensions)}. Add them to additional_allowed_extensions if Cloud SQL supports them now."
+    }
+
+    precondition {
+      condition     = length(local.too_new_extensions) == 0
+      error_message = "Extensions that need a newer PostgreSQL major version than ${var.postgres_version}: ${join(", ", [for name in local.too_new_extensions : "${name} (${local.extension_min_major_versions[name]})"])}."
+    }
+
+    precondition {
+      condition     = alltrue([for db_name in local.pg_cron_databases : db_name == local.cron_database])
+      error_message = "pg_cron can only be installed in the cron database (${local.cron_database}); list it in the extensions of that database only."
+    }
+  }
 }
 
 # ==========================================
@@ -158,10 +304,10 @@ locals {
     # Memory settings (based on instance size) - Cloud SQL expects integers in 8KB pages
     # Note: Cloud SQL has instance-specific limits on these values
     # Using conservative values that work across instance types: ~20% RAM for shared_buffers, ~60% for effective_cache_size
-    shared_buffers       = tostring(floor(local.memory_gb * 200 * 1024 / 8)) # ~20% of RAM in 8KB pages
-    effective_cache_size = tostring(floor(local.memory_gb * 600 * 1024 / 8)) # ~60% of RAM in 8KB pages (capped by Cloud SQL)
+    shared_buffers       = tostring(floor(local.memory_gb * 200 * 1024 / 8))             # ~20% of RAM in 8KB pages
+    effective_cache_size = tostring(floor(local.memory_gb * 600 * 1024 / 8))             # ~60% of RAM in 8KB pages (capped by Cloud SQL)
     maintenance_work_mem = tostring(min(262144, floor(local.memory_gb * 64 * 1024 / 8))) # Max 2GB in 8KB pages
-    work_mem             = tostring(max(512, floor(local.memory_gb * 4 * 1024 / 8))) # Min 4MB in 8KB pages
+    work_mem             = tostring(max(512, floor(local.memory_gb * 4 * 1024 / 8)))     # Min 4MB in 8KB pages
 
     # Checkpoint settings
     checkpoint_completion_target = "0.9"
@@ -206,14 +352,33 @@ locals {
 # DATABASES
 # ==========================================
 
+locals {
+  # Databases the clone source already has are copied with the instance instead of being created
+  cloned_database_names = var.clone_from != null ? [
+    for db_name in var.clone_from.skip_databases : db_name if contains(keys(var.databases), db_name)
+  ] : []
+
+  # Database Migration Service copies the databases from the migration source and needs an empty destination
+  migrated_database_names = var.migration.enabled ? keys(var.databases) : []
+}
+
 resource "google_sql_database" "databases" {
-  for_each = var.databases
+  for_each = {
+    for name, db in var.databases : name => db
+    if !contains(concat(local.cloned_database_names, local.migrated_database_names), name)
+  }
 
   name      = each.key
-  instance  = google_sql_database_instance.postgres.name
+  instance  = local.current_primary.name
   charset   = try(each.value.charset, "UTF8")
   collation = try(each.value.collation, "en_US.UTF8")
   project   = var.project_id
+
+  # A switchover must not replace databases, which the new primary already has through replication.
+  # Re-point them in the state after the switchover (see the README).
+  lifecycle {
+    ignore_changes = [instance]
+  }
 }
 
 # ==========================================
@@ -233,15 +398,32 @@ resource "random_password" "user_passwords" {
 }
 
 # Create users
+# The migration destination is read-only until the migration job is promoted
 resource "google_sql_user" "users" {
-  for_each = var.users
+  for_each = var.migration.enabled && !var.migration.promote ? {} : var.users
 
   name     = each.key
-  instance = google_sql_database_instance.postgres.name
+  instance = local.current_primary.name
   password = coalesce(each.value.password, random_password.user_passwords[each.key].result)
   project  = var.project_id
 
-  depends_on = [random_password.user_passwords]
+  depends_on = [random_password.user_passwords, terraform_data.migration_promote]
+
+  lifecycle {
+    # A switchover must not replace users, which the new primary already has through replication.
+    # Re-point them in the state after the switchover (see the README).
+    ignore_changes = [instance]
+
+    precondition {
+      condition     = alltrue([for db_name in keys(local.user_database_roles[each.key]) : contains(keys(var.databases), db_name)])
+      error_message = "User ${each.key} is scoped to databases that are not in var.databases."
+    }
+
+    precondition {
+      condition     = alltrue([for db_name in concat(keys(coalesce(each.value.custom_grants, {})), keys(coalesce(each.value.raw_grants, {}))) : contains(keys(var.databases), db_name)])
+      error_message = "User ${each.key} has custom_grants or raw_grants for databases that are not in var.databases."
+    }
+  }
 }
 
 # ==========================================
@@ -281,9 +463,175 @@ resource "google_secret_manager_secret_version" "user_passwords" {
 # ==========================================
 
 locals {
+  # Schemas managed in each database; public is always included and schemas default to the database owner
+  database_schemas = {
+    for db_name, db in var.databases :
+    db_name => {
+      for schema_name, schema in merge({ public = { owner = null, grants = {} } }, db.schemas) :
+      schema_name => {
+        owner  = schema.owner != null ? schema.owner : db.owner
+        grants = schema.grants
+      }
+    }
+  }
+
+  # NOLOGIN group roles holding the privileges: <db>_<level> covers every schema of the database,
+  # <db>_<schema>_<level> is created for per-schema grants
+  permission_groups = {
+    for db_name, db_schemas in local.database_schemas :
+    db_name => concat(
+      [
+        for level in ["readonly", "readwrite", "admin"] :
+        { name = "${db_name}_${level}", level = level, schemas = keys(db_schemas) }
+      ],
+      flatten([
+        for schema_name, schema in db_schemas : [
+          for level in distinct(values(schema.grants)) :
+          { name = "${db_name}_${schema_name}_${level}", level = level, schemas = [schema_name] }
+        ]
+      ])
+    )
+  }
+  permission_group_names = flatten([for db_groups in values(local.permission_groups) : [for group in db_groups : group.name]])
+
+  # Access level of each user per database: database_roles first, then role on the listed (or all) databases
+  user_database_roles = {
+    for user_name, user in var.users :
+    user_name => user.database_roles != null ? user.database_roles : (
+      user.role == "custom" ? {} : { for db_name in coalesce(user.databases, keys(var.databases)) : db_name => user.role }
+    )
+  }
+
+  # Group roles each user is made a member of
+  user_group_memberships = {
+    for user_name, user in var.users :
+    user_name => concat(
+      [for db_name, level in local.user_database_roles[user_name] : "${db_name}_${level}"],
+      flatten([
+        for db_name, db_schemas in local.database_schemas : [
+          for schema_name, schema in db_schemas :
+          "${db_name}_${schema_name}_${schema.grants[user_name]}" if contains(keys(schema.grants), user_name)
+        ]
+      ])
+    )
+  }
+
+  # Cluster-wide admin privileges are only granted to admins that are not scoped to specific databases
+  unscoped_admins = [
+    for user_name, user in var.users :
+    user_name if user.role == "admin" && user.databases == null && user.database_roles == null
+  ]
+
+  # Roles whose future objects get default privileges in each database, besides the role running the script
+  database_creators = {
+    for db_name, db in var.databases :
+    db_name => distinct(concat(db.owner != null ? [db.owner] : [], db.creator_roles))
+  }
+
+  # Default privileges each access level receives on objects created later
+  default_privileges = {
+    admin     = ["ALL ON TABLES", "ALL ON SEQUENCES", "ALL ON FUNCTIONS"]
+    readwrite = ["ALL ON TABLES", "ALL ON SEQUENCES", "EXECUTE ON FUNCTIONS"]
+    readonly  = ["SELECT ON TABLES", "SELECT ON SEQUENCES"]
+  }
+
+  # Extensions of each database: var.postgresql_extensions everywhere, overridden and extended per database
+  database_extensions = {
+    for db_name, db in var.databases :
+    db_name => merge(
+      { for name in var.postgresql_extensions : name => { version = null, schema = null, cascade = false } },
+      db.extensions
+    )
+  }
+
+  # Structured and raw custom grants of custom-role users per database, with privileges and object types in SQL form
+  user_custom_grants = {
+    for user_name, user in var.users :
+    user_name => {
+      for db_name in distinct(concat(keys(coalesce(user.custom_grants, {})), keys(coalesce(user.raw_grants, {})))) :
+      db_name => {
+        grants = [
+          for grant in lookup(coalesce(user.custom_grants, {}), db_name, []) : {
+            privileges        = distinct([for privilege in grant.privileges : upper(privilege)])
+            object_type       = upper(grant.object_type)
+            schema            = grant.schema
+            objects           = contains(grant.objects, "ALL") ? [] : grant.objects
+            with_grant_option = grant.with_grant_option
+          }
+        ]
+        raw = lookup(coalesce(user.raw_grants, {}), db_name, [])
+      }
+    } if user.role == "custom" && !contains(keys(var.deprovisioned_users), user_name)
+  }
+
+  # Role that takes over the objects of each deprovisioned user in each database: reassign_to, else the database
+  # owner unless that is the user being removed, else postgres
+  deprovision_reassign_to = {
+    for user_name, user in var.deprovisioned_users : user_name => {
+      for db_name, db in var.databases : db_name => coalesce(user.reassign_to, db.owner == user_name ? null : db.owner, "postgres")
+    }
+  }
+
+  # Every name rendered into SQL, quoted once as an identifier and once as a string literal
+  sql_names = distinct(compact(concat(
+    ["postgres"],
+    keys(var.users),
+    keys(var.databases),
+    keys(var.deprovisioned_users),
+    flatten([for db_extensions in values(local.database_extensions) : [for name, extension in db_extensions : compact([name, extension.version, extension.schema])]]),
+    flatten([for db_schemas in values(local.database_schemas) : [for schema_name, schema in db_schemas : [schema_name, coalesce(schema.owner, "postgres")]]]),
+    local.permission_group_names,
+    flatten([for db in values(var.databases) : db.reassign_owned_from]),
+    flatten(values(local.database_creators)),
+    flatten([for db_grants in values(local.user_custom_grants) : [for db_name, grants in db_grants : concat([db_name], flatten([for grant in grants.grants : concat([grant.schema], grant.objects)]))]]),
+    flatten([for db_targets in values(local.deprovision_reassign_to) : values(db_targets)]),
+    [local.cron_database],
+    compact([for job in values(var.cron_jobs) : job.username])
+  )))
+  # Other values rendered into SQL as string literals only
+  sql_strings = concat(
+    keys(var.cron_jobs),
+    flatten([for job in values(var.cron_jobs) : [job.schedule, job.command, job.database]])
+  )
+  sql_identifiers = { for name in local.sql_names : name => "\"${replace(name, "\"", "\"\"")}\"" }
+  sql_literals    = { for value in distinct(concat(local.sql_names, local.sql_strings)) : value => "'${replace(value, "'", "''")}'" }
+
   postgres_permissions = templatefile("${path.module}/templates/setup_permissions.sql.tpl", {
-    databases = var.databases
-    users     = var.users
+    databases          = var.databases
+    creators           = local.database_creators
+    default_privileges = local.default_privileges
+    admins             = local.unscoped_admins
+    schemas            = local.database_schemas
+    groups             = local.permission_groups
+    memberships        = local.user_group_memberships
+    users              = { for user_name, user in var.users : user_name => user if !contains(keys(var.deprovisioned_users), user_name) }
+    custom_grants      = local.user_custom_grants
+    major_version      = local.postgres_major_version
+    ident              = local.sql_identifiers
+    literal            = local.sql_literals
+  })
+
+  # Reverses the permission script: revokes memberships and privileges and drops the group roles
+  rollback_permissions = templatefile("${path.module}/templates/rollback_permissions.sql.tpl", {
+    databases     = var.databases
+    creators      = local.database_creators
+    admins        = local.unscoped_admins
+    groups        = local.permission_groups
+    memberships   = local.user_group_memberships
+    custom_grants = local.user_custom_grants
+    major_version = local.postgres_major_version
+    ident         = local.sql_identifiers
+    literal       = local.sql_literals
+  })
+
+  deprovision_users = templatefile("${path.module}/templates/deprovision_users.sql.tpl", {
+    databases   = var.databases
+    schemas     = local.database_schemas
+    groups      = local.permission_group_names
+    users       = var.deprovisioned_users
+    reassign_to = local.deprovision_reassign_to
+    ident       = local.sql_identifiers
+    literal     = local.sql_literals
   })
 }
 
@@ -294,6 +642,31 @@ resource "local_file" "permission_script" {
   content  = local.postgres_permissions
 
   file_permission = "0644"
+
+  lifecycle {
+    precondition {
+      condition     = alltrue([for name in local.permission_group_names : length(name) <= 63])
+      error_message = "Group role names (<database>_<level> and <database>_<schema>_<level>) must fit in 63 characters; shorten the database or schema names."
+    }
+  }
+}
+
+resource "local_file" "rollback_script" {
+  count = var.generate_permission_script ? 1 : 0
+
+  filename = "${path.root}/rollback_postgres_permissions.sql"
+  content  = local.rollback_permissions
+
+  file_permission = "0644"
+}
+
+resource "local_file" "deprovision_script" {
+  count = length(var.deprovisioned_users) > 0 ? 1 : 0
+
+  filename = "${path.root}/deprovision_postgres_users.sql"
+  content  = local.deprovision_users
+
+  file_permission = "0644"
 }
 
 # ==========================================
@@ -301,14 +674,71 @@ resource "local_file" "permission_script" {
 # ==========================================
 
 locals {
+  # First PostgreSQL major version on which Cloud SQL offers each extension. Most are available on every version
+  # the module supports (12 and later); the contrib modules added since then start with the version that added them
+  extension_min_major_versions = merge(
+    {
+      for name in [
+        "address_standardizer", "address_standardizer_data_us", "amcheck", "bloom", "btree_gin", "btree_gist",
+        "citext", "cube", "dblink", "dict_int", "dict_xsyn", "earthdistance", "fuzzystrmatch", "google_ml_integration",
+        "hll", "hstore", "intagg", "intarray", "ip4r", "isn", "lo", "ltree", "orafce", "pageinspect", "pg_bigm",
+        "pg_buffercache", "pg_cron", "pg_freespacemap", "pg_hint_plan", "pg_partman", "pg_prewarm", "pg_proctab",
+        "pg_repack", "pg_similarity", "pg_squeeze", "pg_stat_statements", "pg_trgm", "pg_visibility",
+        "pg_wait_sampling", "pgaudit", "pgcrypto", "pgfincore", "pglogical", "pgrouting", "pgrowlocks",
+        "pgstattuple", "pgtap", "plpgsql", "plproxy", "plv8", "postgis", "postgis_raster", "postgis_sfcgal",
+        "postgis_tiger_geocoder", "postgis_topology", "postgres_fdw", "prefix", "rdkit", "refint", "sslinfo", "tablefunc",
+        "tcn", "temporal_tables", "tsm_system_rows", "tsm_system_time", "unaccent", "uuid-ossp", "vector"
+      ] : name => 12
+    },
+    {
+      pg_surgery    = 14
+      pg_walinspect = 15
+    }
+  )
+
+  # Extensions Cloud SQL offers on each supported PostgreSQL major version
+  cloudsql_extensions_by_major_version = {
+    for version in range(12, 19) : tostring(version) => [
+      for name, min_version in local.extension_min_major_versions : name if version >= min_version
+    ]
+  }
+
+  # Every extension the scripts install, across databases
+  requested_extensions = distinct(concat(var.postgresql_extensions, flatten([for db_extensions in values(local.database_extensions) : keys(db_extensions)])))
+
+  # Extensions Cloud SQL does not offer on any version, and extensions it only offers on a newer version
+  unsupported_extensions = [
+    for name in local.requested_extensions :
+    name if !contains(concat(keys(local.extension_min_major_versions), var.additional_allowed_extensions), name)
+  ]
+
+  too_new_extensions = [
+    for name in local.requested_extensions :
+    name if contains(keys(local.extension_min_major_versions), name) && !contains(local.cloudsql_extensions_by_major_version[tostring(local.postgres_major_version)], name)
+  ]
+
+  # Flags an extension needs before CREATE EXTENSION succeeds on Cloud SQL
+  extension_flags = {
+    pg_cron               = { "cloudsql.enable_pg_cron" = "on" }
+    pgaudit               = { "cloudsql.enable_pgaudit" = "on" }
+    pglogical             = { "cloudsql.logical_decoding" = "on", "cloudsql.enable_pglogical" = "on" }
+    google_ml_integration = { "cloudsql.enable_google_ml_integration" = "on" }
+    pg_bigm               = { "cloudsql.enable_pg_bigm" = "on" }
+    pg_hint_plan          = { "cloudsql.enable_pg_hint_plan" = "on" }
+    pg_squeeze            = { "cloudsql.logical_decoding" = "on", "cloudsql.enable_pg_squeeze" = "on" }
+    pg_wait_sampling      = { "cloudsql.enable_pg_wait_sampling" = "on" }
+  }
+  extension_database_flags = merge([for name in local.requested_extensions : lookup(local.extension_flags, name, {})]...)
+
   extensions_script = templatefile("${path.module}/templates/setup_extensions.sql.tpl", {
-    databases  = var.databases
-    extensions = var.postgresql_extensions
+    extensions = local.database_extensions
+    ident      = local.sql_identifiers
+    literal    = local.sql_literals
   })
 }
 
 resource "local_file" "extensions_script" {
-  count = length(var.postgresql_extensions) > 0 ? 1 : 0
+  count = length(flatten([for db_extensions in values(local.database_extensions) : keys(db_extensions)])) > 0 ? 1 : 0
 
   filename = "${path.root}/setup_postgres_extensions.sql"
   content  = local.extensions_script
@@ -316,29 +746,784 @@ resource "local_file" "extensions_script" {
   file_permission = "0644"
 }
 
+# ==========================================
+# PG_CRON JOBS
+# ==========================================
+
+locals {
+  # Databases that list pg_cron; the extension can only live in the cron.database_name database
+  pg_cron_databases = [for db_name, db_extensions in local.database_extensions : db_name if contains(keys(db_extensions), "pg_cron")]
+  pg_cron_enabled   = length(var.cron_jobs) > 0 || length(local.pg_cron_databases) > 0
+  cron_database     = coalesce(var.cron_database_name, try(local.pg_cron_databases[0], null), "postgres")
+
+  cron_flags = local.pg_cron_enabled ? {
+    "cloudsql.enable_pg_cron" = "on"
+    "cron.database_name"      = local.cron_database
+  } : {}
+
+  cron_jobs_script = templatefile("${path.module}/templates/setup_cron_jobs.sql.tpl", {
+    cron_database = local.cron_database
+    jobs          = var.cron_jobs
+    literal       = local.sql_literals
+  })
+}
+
+# Only generated when jobs are declared, as the script unschedules every named job it does not declare
+resource "local_file" "cron_jobs_script" {
+  count = length(var.cron_jobs) > 0 ? 1 : 0
+
+  filename = "${path.root}/setup_postgres_cron_jobs.sql"
+  content  = local.cron_jobs_script
+
+  file_permission = "0644"
+
+  lifecycle {
+    precondition {
+      condition     = alltrue([for job in values(var.cron_jobs) : contains(concat(["postgres"], keys(var.databases)), job.database)])
+      error_message = "Cron job databases must be keys in var.databases or postgres."
+    }
+  }
+}
+
+# ==========================================
+# BACKUP AND DR
+# ==========================================
+
+locals {
+  backup_dr_location = coalesce(var.backup_dr.location, var.region)
+  backup_dr_vault = var.backup_dr.enabled ? coalesce(
+    var.backup_dr.backup_vault,
+    try(google_backup_dr_backup_vault.vault[0].id, null)
+  ) : null
+}
+
+resource "google_backup_dr_backup_vault" "vault" {
+  count = var.backup_dr.enabled && var.backup_dr.backup_vault == null ? 1 : 0
+
+  project         = var.project_id
+  location        = local.backup_dr_location
+  backup_vault_id = coalesce(var.backup_dr.backup_vault_id, "${local.instance_name}-vault")
+  description     = "Backup vault for Cloud SQL instance ${local.instance_name}"
+
+  # Backups in the vault cannot be deleted before this retention has passed
+  backup_minimum_enforced_retention_duration = "${var.backup_dr.minimum_retention_days * 86400}s"
+
+  labels = merge(
+    var.labels,
+    {
+      managed_by = "terraform"
+      module     = "cloud-sql-postgres"
+    }
+  )
+}
+
+resource "google_backup_dr_backup_plan" "plan" {
+  count = var.backup_dr.enabled ? 1 : 0
+
+  project        = var.project_id
+  location       = local.backup_dr_location
+  backup_plan_id = "${local.instance_name}-plan"
+  resource_type  = "sqladmin.googleapis.com/Instance"
+  backup_vault   = local.backup_dr_vault
+
+  backup_rules {
+    rule_id               = lower(var.backup_dr.recurrence)
+    backup_retention_days = var.backup_dr.retention_days
+
+    standard_schedule {
+      recurrence_type  = var.backup_dr.recurrence
+      hourly_frequency = var.backup_dr.recurrence == "HOURLY" ? var.backup_dr.hourly_frequency : null
+      time_zone        = var.backup_dr.time_zone
+
+      backup_window {
+        start_hour_of_day = var.backup_dr.window_start_hour
+        end_hour_of_day   = var.backup_dr.window_end_hour
+      }
+    }
+  }
+}
+
+resource "google_backup_dr_backup_plan_association" "postgres" {
+  count = var.backup_dr.enabled ? 1 : 0
+
+  project                    = var.project_id
+  location                   = local.backup_dr_location
+  backup_plan_association_id = "${local.instance_name}-plan-association"
+  resource_type              = "sqladmin.googleapis.com/Instance"
+  resource                   = "projects/${var.project_id}/instances/${google_sql_database_instance.postgres.name}"
+  backup_plan                = google_backup_dr_backup_plan.plan[0].name
+}
+
+# ==========================================
+# DATABASE MIGRATION SERVICE
+# ==========================================
+
+locals {
+  migration_location = coalesce(var.migration.location, var.region)
+  migration_job_id   = coalesce(var.migration.migration_job_id, "${local.instance_name}-migration")
+
+  # Logical decoding flags required by continuous migration jobs
+  migration_flags = var.migration.enabled ? {
+    "cloudsql.logical_decoding" = "on"
+    "cloudsql.enable_pglogical" = "on"
+  } : {}
+}
+
+# Records whether the instance was created as a migration destination. Database Migration Service needs an empty
+# destination, so migration cannot be turned on for an instance that already has databases.
+resource "terraform_data" "migration_destination" {
+  input = var.migration.enabled
+
+  lifecycle {
+    ignore_changes       = [input]
+    replace_triggered_by = [google_sql_database_instance.postgres.id]
+  }
+}
+
+resource "google_database_migration_service_connection_profile" "migration_source" {
+  count = var.migration.enabled ? 1 : 0
+
+  project               = var.project_id
+  location              = local.migration_location
+  connection_profile_id = "${local.instance_name}-source"
+  display_name          = "Migration source for ${local.instance_name}"
+
+  postgresql {
+    host     = var.migration.source.host
+    port     = var.migration.source.port
+    username = var.migration.source.username
+    password = var.migration_source_password
+
+    dynamic "ssl" {
+      for_each = var.migration.source.ssl_type != "NONE" ? [1] : []
+      content {
+        type               = var.migration.source.ssl_type
+        ca_certificate     = var.migration.source.ca_certificate
+        client_certificate = var.migration.source.client_certificate
+        client_key         = var.migration.source.client_key
+      }
+    }
+  }
+
+  labels = merge(
+    var.labels,
+    {
+      managed_by = "terraform"
+      module     = "cloud-sql-postgres"
+    }
+  )
+}
+
+resource "google_database_migration_service_connection_profile" "migration_destination" {
+  count = var.migration.enabled ? 1 : 0
+
+  project               = var.project_id
+  location              = local.migration_location
+  connection_profile_id = "${local.instance_name}-destination"
+  display_name          = "Migration destination ${local.instance_name}"
+
+  postgresql {
+    cloud_sql_id = google_sql_database_instance.postgres.name
+  }
+
+  lifecycle {
+    precondition {
+      condition     = terraform_data.migration_destination.output
+      error_message = "migration.enabled must be set when the instance is created. Database Migration Service needs an empty destination, and turning it on later would remove the existing databases from the configuration."
+    }
+  }
+
+  labels = merge(
+    var.labels,
+    {
+      managed_by = "terraform"
+      module     = "cloud-sql-postgres"
+    }
+  )
+}
+
+resource "google_database_migration_service_migration_job" "migration" {
+  count = var.migration.enabled ? 1 : 0
+
+  project          = var.project_id
+  location         = local.migration_location
+  migration_job_id = local.migration_job_id
+  display_name     = "Migration into ${local.instance_name}"
+  type             = "CONTINUOUS"
+  source           = google_database_migration_service_connection_profile.migration_source[0].name
+  destination      = google_database_migration_service_connection_profile.migration_destination[0].name
+
+  dynamic "vpc_peering_connectivity" {
+    for_each = var.migration.connectivity == "VPC_PEERING" ? [1] : []
+    content {
+      vpc = coalesce(var.migration.vpc, var.private_network_id)
+    }
+  }
+
+  dynamic "static_ip_connectivity" {
+    for_each = var.migration.connectivity == "STATIC_IP" ? [1] : []
+    content {}
+  }
+
+  dynamic "performance_config" {
+    for_each = var.migration.dump_parallel_level != null ? [1] : []
+    content {
+      dump_parallel_level = var.migration.dump_parallel_level
+    }
+  }
+
+  labels = merge(
+    var.labels,
+    {
+      managed_by = "terraform"
+      module     = "cloud-sql-postgres"
+    }
+  )
+
+  lifecycle {
+    precondition {
+      condition     = var.migration.connectivity != "VPC_PEERING" || coalesce(var.migration.vpc, var.private_network_id, "none") != "none"
+      error_message = "VPC peering connectivity needs migration.vpc or private_network_id."
+    }
+  }
+}
+
+# The migration API has no declarative promote, so the cutover runs once through gcloud
+resource "terraform_data" "migration_promote" {
+  count = var.migration.enabled && var.migration.promote ? 1 : 0
+
+  triggers_replace = [google_database_migration_service_migration_job.migration[0].name]
+
+  provisioner "local-exec" {
+    command = "gcloud database-migration migration-jobs promote ${local.migration_job_id} --region=${local.migration_location} --project=${var.project_id}"
+  }
+}
+
+# ==========================================
+# SCHEDULED LOGICAL EXPORTS
+# ==========================================
+
+locals {
+  exports_bucket_name = coalesce(var.exports.bucket_name, "${local.instance_name}-exports")
+  exports_scheduler_service_account = var.exports.enabled ? coalesce(
+    var.exports.service_account_email,
+    try(google_service_account.exports_scheduler[0].email, null)
+  ) : null
+
+  # Exports run on the instance holding the primary role, so both the primary and the DR replica write to the bucket
+  # Cloud SQL runs one export at a time per instance and the scheduler only retries a few times, so databases
+  # without an override export one after another, stagger_minutes apart from the minute and hour of the schedule.
+  # Past midnight the day fields stay the same.
+  exports_schedule_fields = regexall("\\S+", var.exports.schedule)
+  exports_staggered_names = [for name in keys(var.databases) : name if !contains(keys(var.exports.schedule_overrides), name)]
+  exports_schedules = merge(
+    {
+      for i, name in local.exports_staggered_names : name => var.exports.stagger_minutes == 0 ? var.exports.schedule : format(
+        "%d %d %s",
+        (tonumber(local.exports_schedule_fields[0]) + i * var.exports.stagger_minutes) % 60,
+        (tonumber(local.exports_schedule_fields[1]) + floor((tonumber(local.exports_schedule_fields[0]) + i * var.exports.stagger_minutes) / 60)) % 24,
+        join(" ", slice(local.exports_schedule_fields, 2, 5))
+      )
+    },
+    var.exports.schedule_overrides
+  )
+
+  exports_writers = var.exports.enabled ? merge(
+    { primary = google_sql_database_instance.postgres.service_account_email_address },
+    local.dr_replica_key != null ? { dr = google_sql_database_instance.read_replicas[local.dr_replica_key].service_account_email_address } : {}
+  ) : {}
+}
+
+resource "google_storage_bucket" "exports" {
+  count = var.exports.enabled ? 1 : 0
+
+  name                        = local.exports_bucket_name
+  location                    = coalesce(var.exports.bucket_location, var.region)
+  project                     = var.project_id
+  uniform_bucket_level_access = true
+  public_access_prevention    = "enforced"
+
+  # Each export overwrites the object for its database; older exports are kept as noncurrent versions
+  versioning {
+    enabled = true
+  }
+
+  lifecycle_rule {
+    condition {
+      days_since_noncurrent_time = var.exports.retention_days
+    }
+    action {
+      type = "Delete"
+    }
+  }
+
+  dynamic "lifecycle_rule" {
+    for_each = var.exports.coldline_after_days != null ? [1] : []
+    content {
+      condition {
+        age = var.exports.coldline_after_days
+      }
+      action {
+        type          = "SetStorageClass"
+        storage_class = "COLDLINE"
+      }
+    }
+  }
+
+  labels = merge(
+    var.labels,
+    {
+      managed_by = "terraform"
+      module     = "cloud-sql-postgres"
+      instance   = local.instance_name
+    }
+  )
+}
+
+# Allow the instance service accounts to write export files
+resource "google_storage_bucket_iam_member" "exports_writer" {
+  for_each = local.exports_writers
+
+  bucket = google_storage_bucket.exports[0].name
+  role   = "roles/storage.objectAdmin"
+  member = "serviceAccount:${each.value}"
+}
+
+resource "google_service_account" "exports_scheduler" {
+  count = var.exports.enabled && var.exports.service_account_email == null ? 1 : 0
+
+  account_id   = "sqlexp-${substr(md5(local.instance_name), 0, 8)}"
+  display_name = "Cloud SQL exports for ${local.instance_name}"
+  project      = var.project_id
+}
+
+# The scheduler only needs to start exports, so it gets a role with just that permission
+resource "google_project_iam_custom_role" "exports_scheduler" {
+  count = var.exports.enabled && var.exports.service_account_email == null ? 1 : 0
+
+  project     = var.project_id
+  role_id     = "cloudSqlExport_${substr(md5(local.instance_name), 0, 8)}"
+  title       = "Cloud SQL export for ${local.instance_name}"
+  permissions = ["cloudsql.instances.export"]
+}
+
+# Scoped to the instances of this module that can hold the primary role
+resource "google_project_iam_member" "exports_scheduler" {
+  count = var.exports.enabled && var.exports.service_account_email == null ? 1 : 0
+
+  project = var.project_id
+  role    = google_project_iam_custom_role.exports_scheduler[0].name
+  member  = "serviceAccount:${google_service_account.exports_scheduler[0].email}"
+
+  condition {
+    title = "${local.instance_name}-exports"
+    expression = join(" || ", [
+      for name in compact([local.instance_name, local.dr_replica_instance_name]) :
+      "resource.name == \"projects/${var.project_id}/instances/${name}\""
+    ])
+  }
+}
+
+resource "google_cloud_scheduler_job" "exports" {
+  for_each = var.exports.enabled ? var.databases : {}
+
+  name      = "${local.instance_name}-export-${each.key}"
+  project   = var.project_id
+  region    = var.region
+  schedule  = local.exports_schedules[each.key]
+  time_zone = var.exports.time_zone
+
+  description = "Export database ${each.key} of ${local.instance_name} to gs://${local.exports_bucket_name}"
+
+  # Cloud SQL runs one operation at a time, so retry when another export is still running
+  retry_config {
+    retry_count          = 3
+    min_backoff_duration = "300s"
+  }
+
+  http_target {
+    http_method = "POST"
+    uri         = "https://sqladmin.googleapis.com/v1/projects/${var.project_id}/instances/${local.dr_switchover_active ? local.current_primary_instance_name : google_sql_database_instance.postgres.name}/export"
+    headers = {
+      "Content-Type" = "application/json"
+    }
+    body = base64encode(jsonencode({
+      exportContext = {
+        fileType  = "SQL"
+        uri       = "gs://${google_storage_bucket.exports[0].name}/${each.key}/${each.key}.sql.gz"
+        databases = [each.key]
+        offload   = var.exports.offload
+      }
+    }))
+
+    oauth_token {
+      service_account_email = local.exports_scheduler_service_account
+      scope                 = "https://www.googleapis.com/auth/cloud-platform"
+    }
+  }
+
+  depends_on = [google_storage_bucket_iam_member.exports_writer, google_project_iam_member.exports_scheduler]
+}
+
+# ==========================================
+# CONNECTION POOLING
+# ==========================================
+
+locals {
+  # Managed pooling where the edition supports it, otherwise a generated PgBouncer configuration
+  managed_connection_pooling = var.connection_pooling.enabled && local.final_edition == "ENTERPRISE_PLUS"
+  pgbouncer_enabled          = var.connection_pooling.enabled && !local.managed_connection_pooling
+
+  managed_connection_pool_flags = {
+    pool_mode                      = var.connection_pooling.pool_mode
+    max_pool_size                  = tostring(var.connection_pooling.pool_size)
+    max_client_connections         = tostring(var.connection_pooling.max_client_connections)
+    client_connection_idle_timeout = tostring(var.connection_pooling.client_idle_timeout)
+  }
+}
+
+resource "local_file" "pgbouncer_config" {
+  count = local.pgbouncer_enabled ? 1 : 0
+
+  filename = "${path.root}/pgbouncer.ini"
+  content = templatefile("${path.module}/templates/pgbouncer.ini.tpl", {
+    databases   = var.databases
+    host        = var.private_network_id != null ? local.current_primary.private_ip_address : local.current_primary.public_ip_address
+    server_tls  = var.ssl_mode == "ALLOW_UNENCRYPTED_AND_ENCRYPTED" ? "prefer" : "require"
+    pooling     = var.connection_pooling
+    userlist    = "pgbouncer_userlist.txt"
+    instance    = local.instance_name
+    listen_port = var.connection_pooling.listen_port
+  })
+
+  file_permission = "0644"
+}
+
+resource "local_sensitive_file" "pgbouncer_userlist" {
+  count = local.pgbouncer_enabled ? 1 : 0
+
+  filename = "${path.root}/pgbouncer_userlist.txt"
+  content = templatefile("${path.module}/templates/pgbouncer_userlist.txt.tpl", {
+    passwords = {
+      for user_name, user_config in var.users :
+      user_name => coalesce(user_config.password, random_password.user_passwords[user_name].result)
+    }
+  })
+
+  file_permission = "0600"
+}
+
 # ==========================================
 # READ REPLICAS
 # ==========================================
 
+locals {
+  # Enterprise Plus disaster recovery replica and switchover state
+  dr_replica_key           = one([for name, replica in var.read_replicas : name if coalesce(replica.disaster_recovery, false)])
+  dr_replica_instance_name = local.dr_replica_key != null ? "${local.instance_name}-${local.dr_replica_key}" : null
+  dr_switchover_active     = var.dr_switchover && local.dr_replica_key != null
+
+  # Instance currently holding the primary role
+  current_primary_instance_name = local.dr_switchover_active ? local.dr_replica_instance_name : local.instance_name
+
+  # Connection details of the instance holding the primary role, for users, databases and clients
+  current_primary = local.dr_switchover_active ? {
+    name               = google_sql_database_instance.read_replicas[local.dr_replica_key].name
+    connection_name    = google_sql_database_instance.read_replicas[local.dr_replica_key].connection_name
+    public_ip_address  = google_sql_database_instance.read_replicas[local.dr_replica_key].public_ip_address
+    private_ip_address = google_sql_database_instance.read_replicas[local.dr_replica_key].private_ip_address
+    } : {
+    name               = google_sql_database_instance.postgres.name
+    connection_name    = google_sql_database_instance.postgres.connection_name
+    public_ip_address  = google_sql_database_instance.postgres.public_ip_address
+    private_ip_address = google_sql_database_instance.postgres.private_ip_address
+  }
+
+  # Replication level of each replica: 1 replicates from the primary, 2 and 3 cascade from another replica.
+  # Each level is a separate resource so that source replicas are created first.
+  read_replica_levels = {
+    for name, replica in var.read_replicas : name => (
+      replica.source == null ? 1 : (var.read_replicas[replica.source].source == null ? 2 : 3)
+    )
+  }
+
+  # Resolve per-replica overrides, falling back to the primary instance settings
+  read_replica_settings = {
+    for name, replica in var.read_replicas : name => {
+      name                = "${local.instance_name}-${name}"
+      region              = coalesce(replica.region, var.region)
+      deletion_protection = coalesce(replica.deletion_protection, local.deletion_protection)
+      failover_target     = coalesce(replica.failover_target, false)
+      disaster_recovery   = coalesce(replica.disaster_recovery, false)
+      is_primary          = local.dr_switchover_active && name == local.dr_replica_key
+
+      settings = {
+        tier                  = coalesce(replica.machine_type, local.final_machine_type)
+        edition               = coalesce(replica.edition, local.final_edition)
+        disk_type             = var.disk_type
+        disk_size             = coalesce(replica.disk_size, local.final_disk_size)
+        disk_autoresize       = var.disk_autoresize
+        disk_autoresize_limit = coalesce(replica.disk_autoresize_limit_gb, var.disk_autoresize_limit_gb)
+        availability_type     = coalesce(replica.availability_type, "ZONAL")
+        ipv4_enabled          = var.ipv4_enabled
+        private_network       = var.private_network_id
+        ssl_mode              = var.ssl_mode
+        authorized_networks   = replica.authorized_networks != null ? replica.authorized_networks : var.authorized_networks
+        data_cache_enabled    = coalesce(replica.data_cache_enabled, var.data_cache_enabled)
+        connector_enforcement = coalesce(replica.connector_enforcement, var.connector_enforcement)
+        database_flags        = coalesce(replica.database_flags, {})
+
+        maintenance_window = {
+          day          = coalesce(try(replica.maintenance_window.day, null), var.maintenance_window_day)
+          hour         = coalesce(try(replica.maintenance_window.hour, null), var.maintenance_window_hour)
+          update_track = coalesce(try(replica.maintenance_window.update_track, null), var.maintenance_window_update_track)
+        }
+
+        insights_config = {
+          query_insights_enabled  = coalesce(try(replica.insights_config.query_insights_enabled, null), var.query_insights_enabled)
+          query_string_length     = coalesce(try(replica.insights_config.query_string_length, null), var.query_string_length)
+          record_application_tags = coalesce(try(replica.insights_config.record_application_tags, null), var.record_application_tags)
+          record_client_address   = coalesce(try(replica.insights_config.record_client_address, null), var.record_client_address)
+          query_plans_per_minute  = coalesce(try(replica.insights_config.query_plans_per_minute, null), var.query_plans_per_minute)
+        }
+
+        labels = merge(
+          var.labels,
+          {
+            managed_by  = "terraform"
+            module      = "cloud-sql-postgres"
+            environment = var.environment
+            type        = "read-replica"
+            primary     = local.instance_name
+          },
+          coalesce(replica.labels, {})
+        )
+      }
+
+      # The primary's backup schedule, used by the DR replica while it holds the primary role
+      backup = {
+        enabled                        = var.backup_enabled
+        start_time                     = var.backup_start_time
+        location                       = try(coalesce(replica.backup_location, var.backup_location), null)
+        point_in_time_recovery         = var.point_in_time_recovery
+        transaction_log_retention_days = var.transaction_log_retention_days
+        retained_backups               = local.retained_backups
+      }
+    }
+  }
+}
+
+# Replicas of the primary instance, including the DR replica. Cascading replicas are created by the
+# read-replica submodule, once per replication level, after the replica they replicate from.
 resource "google_sql_database_instance" "read_replicas" {
-  for_each = var.read_replicas
+  for_each = { for name, replica in local.read_replica_settings : name => replica if local.read_replica_levels[name] == 1 }
+
+  name                = each.value.name
+  database_version    = var.postgres_version
+  region              = each.value.region
+  deletion_protection = each.value.deletion_protection
+  project             = var.project_id
+
+  # During a switchover the DR replica becomes the primary and the other replicas follow it
+  instance_type        = each.value.is_primary ? "CLOUD_SQL_INSTANCE" : "READ_REPLICA_INSTANCE"
+  master_instance_name = each.value.is_primary ? null : (local.dr_switchover_active ? local.current_primary_instance_name : google_sql_database_instance.postgres.name)
+  replica_names = each.value.is_primary ? concat(
+    [local.instance_name],
+    [for name, level in local.read_replica_levels : local.read_replica_settings[name].name if level == 1 && name != each.key]
+  ) : null
+
+  dynamic "replica_configuration" {
+    for_each = each.value.is_primary ? [] : [1]
+    content {
+      failover_target = each.value.failover_target
+    }
+  }
+
+  dynamic "replication_cluster" {
+    for_each = each.value.is_primary ? [1] : []
+    content {
+      failover_dr_replica_name = "${var.project_id}:${local.instance_name}"
+    }
+  }
+
+  settings {
+    tier              = each.value.settings.tier
+    edition           = each.value.settings.edition
+    disk_type         = each.value.settings.disk_type
+    disk_size         = each.value.settings.disk_size
+    disk_autoresize   = each.value.settings.disk_autoresize
+    availability_type = each.value.settings.availability_type
+
+    disk_autoresize_limit = each.value.settings.disk_autoresize_limit
+
+    # Replicas do not take backups, but keep the location for use after promotion.
+    # The DR replica takes over the primary's backup schedule while it holds the primary role.
+    dynamic "backup_configuration" {
+      for_each = each.value.backup.location != null || each.value.is_primary ? [1] : []
+      content {
+        enabled                        = each.value.is_primary ? each.value.backup.enabled : false
+        start_time                     = each.value.is_primary ? each.value.backup.start_time : null
+        location                       = each.value.backup.location
+        point_in_time_recovery_enabled = each.value.is_primary ? each.value.backup.point_in_time_recovery : null
+        transaction_log_retention_days = each.value.is_primary ? each.value.backup.transaction_log_retention_days : null
+
+        dynamic "backup_retention_settings" {
+          for_each = each.value.is_primary ? [1] : []
+          content {
+            retained_backups = each.value.backup.retained_backups
+            retention_unit   = "COUNT"
+          }
+        }
+      }
+    }
+
+    ip_configuration {
+      ipv4_enabled    = each.value.settings.ipv4_enabled
+      private_network = each.value.settings.private_network
+      ssl_mode        = each.value.settings.ssl_mode
+
+      dynamic "authorized_networks" {
+        for_each = each.value.settings.authorized_networks
+        content {
+          name  = authorized_networks.value.name
+          value = authorized_networks.value.cidr
+        }
+      }
+    }
+
+    maintenance_window {
+      day          = each.value.settings.maintenance_window.day
+      hour         = each.value.settings.maintenance_window.hour
+      update_track = each.value.settings.maintenance_window.update_track
+    }
+
+    insights_config {
+      query_insights_enabled  = each.value.settings.insights_config.query_insights_enabled
+      query_string_length     = each.value.settings.insights_config.query_string_length
+      record_application_tags = each.value.settings.insights_config.record_application_tags
+      record_client_address   = each.value.settings.insights_config.record_client_address
+      query_plans_per_minute  = each.value.settings.insights_config.query_plans_per_minute
+    }
+
+    # Data cache for Enterprise Plus
+    dynamic "data_cache_config" {
+      for_each = each.value.settings.edition == "ENTERPRISE_PLUS" && each.value.settings.data_cache_enabled ? [1] : []
+      content {
+        data_cache_enabled = true
+      }
+    }
+
+    user_labels = each.value.settings.labels
+
+    connector_enforcement = each.value.settings.connector_enforcement
+
+    dynamic "database_flags" {
+      for_each = merge(
+        {
+          # Read replica specific flags
+          hot_standby_feedback        = "on"
+          max_standby_streaming_delay = "30s"
+        },
+        each.value.settings.database_flags
+      )
+      content {
+        name  = database_flags.key
+        value = database_flags.value
+      }
+    }
+  }
+
+  timeouts {
+    create = var.timeouts.create
+    update = var.timeouts.update
+    delete = var.timeouts.delete
+  }
+
+  lifecycle {
+    # A switchover changes the instance the replicas replicate from, which would otherwise replace them.
+    # New replicas still start from the instance holding the primary role.
+    ignore_changes = [master_instance_name]
+
+    precondition {
+      condition     = !each.value.disaster_recovery || (local.final_edition == "ENTERPRISE_PLUS" && each.value.settings.edition == "ENTERPRISE_PLUS")
+      error_message = "The disaster recovery replica requires ENTERPRISE_PLUS edition on both the primary and the replica."
+    }
+  }
+}
+
+# Cascading replicas that replicate from a first-level replica
+module "read_replicas_level2" {
+  source   = "./modules/read-replica"
+  for_each = { for name, replica in local.read_replica_settings : name => replica if local.read_replica_levels[name] == 2 }
+
+  name                 = each.value.name
+  project_id           = var.project_id
+  region               = each.value.region
+  database_version     = var.postgres_version
+  master_instance_name = google_sql_database_instance.read_replicas[var.read_replicas[each.key].source].name
+  deletion_protection  = each.value.deletion_protection
+  failover_target      = each.value.failover_target
+  settings             = each.value.settings
+  backup_location      = each.value.backup.location
+  timeouts             = var.timeouts
+}
+
+# Cascading replicas that replicate from a second-level replica
+module "read_replicas_level3" {
+  source   = "./modules/read-replica"
+  for_each = { for name, replica in local.read_replica_settings : name => replica if local.read_replica_levels[name] == 3 }
+
+  name                 = each.value.name
+  project_id           = var.project_id
+  region               = each.value.region
+  database_version     = var.postgres_version
+  master_instance_name = module.read_replicas_level2[var.read_replicas[each.key].source].name
+  deletion_protection  = each.value.deletion_protection
+  failover_target      = each.value.failover_target
+  settings             = each.value.settings
+  backup_location      = each.value.backup.location
+  timeouts             = var.timeouts
+}
+
+# ==========================================
+# READ POOLS
+# ==========================================
+
+resource "google_sql_database_instance" "read_pools" {
+  for_each = var.read_pools
 
   name                 = "${local.instance_name}-${each.key}"
   database_version     = var.postgres_version
-  region               = coalesce(each.value.region, var.region)
-  master_instance_name = google_sql_database_instance.postgres.name
+  region               = var.region
+  instance_type        = "READ_POOL_INSTANCE"
+  master_instance_name = local.dr_switchover_active ? local.current_primary_instance_name : google_sql_database_instance.postgres.name
+  node_count           = each.value.autoscaling == null ? coalesce(each.value.node_count, 1) : null
+  deletion_protection  = local.deletion_protection
   project              = var.project_id
 
-  replica_configuration {
-    failover_target = coalesce(each.value.failover_target, false)
-  }
-
   settings {
-    tier              = coalesce(each.value.machine_type, local.final_machine_type)
-    disk_type         = var.disk_type
-    disk_size         = coalesce(each.value.disk_size, local.final_disk_size)
-    disk_autoresize   = var.disk_autoresize
-    availability_type = coalesce(each.value.availability_type, "ZONAL")
+    tier      = coalesce(each.value.machine_type, local.final_machine_type)
+    edition   = local.final_edition
+    disk_type = var.disk_type
+    disk_size = local.final_disk_size
+
+    # Autoscaling between node count bounds
+    dynamic "read_pool_auto_scale_config" {
+      for_each = each.value.autoscaling != null ? [each.value.autoscaling] : []
+      content {
+        enabled                    = true
+        min_node_count             = read_pool_auto_scale_config.value.min_node_count
+        max_node_count             = read_pool_auto_scale_config.value.max_node_count
+        disable_scale_in           = read_pool_auto_scale_config.value.disable_scale_in
+        scale_in_cooldown_seconds  = read_pool_auto_scale_config.value.scale_in_cooldown_seconds
+        scale_out_cooldown_seconds = read_pool_auto_scale_config.value.scale_out_cooldown_seconds
+
+        target_metrics {
+          metric       = "AVERAGE_CPU_UTILIZATION"
+          target_value = read_pool_auto_scale_config.value.target_cpu_utilization
+        }
+      }
+    }
 
     ip_configuration {
       ipv4_enabled    = var.ipv4_enabled
@@ -357,22 +1542,18 @@ resource "google_sql_database_instance" "read_replicas" {
     user_labels = merge(
       var.labels,
       {
-        managed_by = "terraform"
-        module     = "cloud-sql-postgres"
-        type       = "read-replica"
-        primary    = local.instance_name
+        managed_by  = "terraform"
+        module      = "cloud-sql-postgres"
+        environment = var.environment
+        type        = "read-pool"
+        primary     = local.instance_name
       }
     )
 
+    connector_enforcement = var.connector_enforcement
+
     dynamic "database_flags" {
-      for_each = merge(
-        {
-          # Read replica specific flags
-          hot_standby_feedback        = "on"
-          max_standby_streaming_delay = "30s"
-        },
-        try(each.value.database_flags, {})
-      )
+      for_each = try(each.value.database_flags, {})
       content {
         name  = database_flags.key
         value = database_flags.value
@@ -385,4 +1566,15 @@ resource "google_sql_database_instance" "read_replicas" {
     update = var.timeouts.update
     delete = var.timeouts.delete
   }
+
+  lifecycle {
+    # A switchover changes the instance the pool reads from, which would otherwise replace the pool.
+    # New pools still start from the instance holding the primary role.
+    ignore_changes = [master_instance_name]
+
+    precondition {
+      condition     = local.final_edition == "ENTERPRISE_PLUS"
+      error_message = "Read pools require the ENTERPRISE_PLUS edition."
+    }
+  }
 }
@@ -0,0 +1,278 @@
+/**
+ * # PostgreSQL Native Permissions
+ *
+ * Manages the permission model of the parent module (group roles, grants, default privileges,
+ * schemas and extensions) as Terraform resources through the cyrilgdn/postgresql provider,
+ * so that drift in the database shows up in the plan.
+ *
+ * Database ownership transfer and raw_grants stay in the generated SQL script.
+ *
+ * The module does not configure the provider. Call it next to the Cloud SQL module with a
+ * provider that can reach the instance, and pass the permission_model output:
+ *
+ * ```hcl
+ * provider "postgresql" {
+ *   host      = module.postgres.private_ip_address
+ *   username  = "app_admin"
+ *   password  = module.postgres.user_passwords["app_admin"]
+ *   sslmode   = "require"
+ *   superuser = false
+ * }
+ *
+ * module "postgres_permissions" {
+ *   source = "path/to/terraform-gcp-postgresql//modules/postgresql-native"
+ *
+ *   username      = "app_admin"
+ *   databases     = module.postgres.permission_model.databases
+ *   groups        = module.postgres.permission_model.groups
+ *   memberships   = module.postgres.permission_model.memberships
+ *   admins        = module.postgres.permission_model.admins
+ *   grants        = module.postgres.permission_model.grants
+ *   major_version = module.postgres.permission_model.major_version
+ * }
+ * ```
+ */
+
+locals {
+  # Privileges of each access level per object type
+  level_privileges = {
+    admin = {
+      database = ["CONNECT", "CREATE", "TEMPORARY"]
+      schema   = ["USAGE", "CREATE"]
+      table    = ["SELECT", "INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER"]
+      sequence = ["USAGE", "SELECT", "UPDATE"]
+      function = ["EXECUTE"]
+    }
+    readwrite = {
+      database = ["CONNECT"]
+      schema   = ["USAGE", "CREATE"]
+      table    = ["SELECT", "INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER"]
+      sequence = ["USAGE", "SELECT", "UPDATE"]
+      function = ["EXECUTE"]
+    }
+    readonly = {
+      database = ["CONNECT"]
+      schema   = ["USAGE"]
+      table    = ["SELECT"]
+      sequence = ["SELECT"]
+      function = []
+    }
+  }
+
+  groups = {
+    for group in flatten([
+      for db_key, db_groups in var.groups : [
+        for group in db_groups : merge(group, { database = var.databases[db_key].name })
+      ]
+    ]) : group.name => group
+  }
+
+  # One entry per group, schema and object type that has privileges
+  schema_grants = {
+    for grant in flatten([
+      for group in values(local.groups) : [
+        for schema_name in group.schemas : [
+          for object_type in ["schema", "table", "sequence", "function"] : {
+            key         = "${group.name}/${schema_name}/${object_type}"
+            group       = group.name
+            database    = group.database
+            schema      = schema_name
+            object_type = object_type
+            privileges  = local.level_privileges[group.level][object_type]
+          } if length(local.level_privileges[group.level][object_type]) > 0
+        ]
+      ]
+    ]) : grant.key => grant
+  }
+
+  # Default privileges on objects created later by each creator role of the database
+  creator_default_privileges = {
+    for grant in flatten([
+      for key, grant in local.schema_grants : [
+        for db in values(var.databases) : [
+          # schema_objects already covers the connecting role, and a second resource for it would fight over the same entry
+          for creator in db.creators : merge(grant, { key = "${key}/${creator}", owner = creator }) if creator != var.username
+        ] if db.name == grant.database
+      ] if grant.object_type != "schema"
+    ]) : grant.key => grant
+  }
+
+  schemas = {
+    for schema in flatten([
+      for db in values(var.databases) : [
+        for schema_name, schema in db.schemas : {
+          key      = "${db.name}/${schema_name}"
+          database = db.name
+          name     = schema_name
+          owner    = schema.owner
+        } if schema_name != "public"
+      ]
+    ]) : schema.key => schema
+  }
+
+  memberships = {
+    for membership in flatten([
+      for role, group_names in var.memberships : [
+        for group_name in group_names : { role = role, group = group_name }
+      ]
+    ]) : "${membership.role}/${membership.group}" => membership
+  }
+
+  admin_roles = var.major_version >= 14 ? {
+    for membership in flatten([
+      for role in var.admins : [
+        for group_name in ["pg_read_all_data", "pg_write_all_data"] : { role = role, group = group_name }
+      ]
+    ]) : "${membership.role}/${membership.group}" => membership
+  } : {}
+
+  custom_grants = {
+    for index, grant in var.grants :
+    "${grant.role}/${grant.database}/${index}" => merge(grant, {
+      database   = var.databases[grant.database].name
+      privileges = contains(grant.privileges, "ALL") ? local.level_privileges.admin[grant.object_type] : grant.privileges
+    })
+  }
+
+  extensions = {
+    for extension in flatten([
+      for db in values(var.databases) : [
+        for name, extension in db.extensions : merge(extension, { database = db.name, name = name })
+      ]
+    ]) : "${extension.database}/${extension.name}" => extension
+  }
+}
+
+# ==========================================
+# SCHEMAS
+# ==========================================
+
+resource "postgresql_schema" "schemas" {
+  for_each = local.schemas
+
+  name          = each.value.name
+  database      = each.value.database
+  owner         = each.value.owner
+  if_not_exists = true
+  drop_cascade  = false
+}
+
+# ==========================================
+# GROUP ROLES
+# ==========================================
+
+resource "postgresql_role" "groups" {
+  for_each = local.groups
+
+  name  = each.key
+  login = false
+}
+
+resource "postgresql_grant" "database" {
+  for_each = local.groups
+
+  database    = each.value.database
+  role        = postgresql_role.groups[each.key].name
+  object_type = "database"
+  privileges  = local.level_privileges[each.value.level].database
+}
+
+resource "postgresql_grant" "schema_objects" {
+  for_each = local.schema_grants
+
+  database    = each.value.database
+  role        = postgresql_role.groups[each.value.group].name
+  schema      = each.value.schema
+  object_type = each.value.object_type
+  privileges  = each.value.privileges
+
+  depends_on = [postgresql_schema.schemas]
+}
+
+# PostgreSQL 15 and later no longer let every role create objects in public; apply the same default on older versions
+resource "postgresql_grant" "public_schema" {
+  for_each = var.major_version < 15 ? var.databases : {}
+
+  database    = each.value.name
+  role        = "public"
+  schema      = "public"
+  object_type = "schema"
+  privileges  = ["USAGE"]
+}
+
+# Privileges on objects the connecting role creates later
+resource "postgresql_default_privileges" "schema_objects" {
+  for_each = { for key, grant in local.schema_grants : key => grant if grant.object_type != "schema" }
+
+  database    = each.value.database
+  role        = postgresql_role.groups[each.value.group].name
+  owner       = var.username
+  schema      = each.value.schema
+  object_type = each.value.object_type
+  privileges  = each.value.privileges
+
+  depends_on = [postgresql_schema.schemas]
+}
+
+# Structured custom grants of custom-role users
+resource "postgresql_grant" "custom" {
+  for_each = local.custom_grants
+
+  database          = each.value.database
+  role              = each.value.role
+  schema            = each.value.schema
+  object_type       = each.value.object_type
+  objects           = each.value.objects
+  privileges        = each.value.privileges
+  with_grant_option = each.value.with_grant_option
+
+  depends_on = [postgresql_schema.schemas]
+}
+
+# Privileges on objects the creator roles of each database create later
+resource "postgresql_default_privileges" "creators" {
+  for_each = local.creator_default_privileges
+
+  database    = each.value.database
+  role        = postgresql_role.groups[each.value.group].name
+  owner       = each.value.owner
+  schema      = each.value.schema
+  object_type = each.value.object_type
+  privileges  = each.value.privileges
+
+  depends_on = [postgresql_schema.schemas]
+}
+
+# ==========================================
+# MEMBERSHIPS
+# ==========================================
+
+resource "postgresql_grant_role" "memberships" {
+  for_each = local.memberships
+
+  role       = each.value.role
+  grant_role = postgresql_role.groups[each.value.group].name
+}
+
+resource "postgresql_grant_role" "admins" {
+  for_each = local.admin_roles
+
+  role       = each.value.role
+  grant_role = each.value.group
+}
+
+# ==========================================
+# EXTENSIONS
+# ==========================================
+
+resource "postgresql_extension" "extensions" {
+  for_each = local.extensions
+
+  name           = each.value.name
+  database       = each.value.database
+  version        = each.value.version
+  schema         = each.value.schema
+  create_cascade = each.value.cascade
+
+  depends_on = [postgresql_schema.schemas]
+}
@@ -0,0 +1,14 @@
+output "group_roles" {
+  description = "Names of the managed group roles"
+  value       = [for role in postgresql_role.groups : role.name]
+}
+
+output "schemas" {
+  description = "Managed schemas as database/schema keys"
+  value       = keys(postgresql_schema.schemas)
+}
+
+output "extensions" {
+  description = "Managed extensions as database/extension keys"
+  value       = keys(postgresql_extension.extensions)
+}
@@ -0,0 +1,69 @@
+# ==========================================
+# CONNECTION
+# ==========================================
+
+variable "username" {
+  description = "Role the postgresql provider connects as; objects it creates later get the group default privileges. On Cloud SQL it must be a member of cloudsqlsuperuser"
+  type        = string
+}
+
+# ==========================================
+# PERMISSION MODEL
+# ==========================================
+
+variable "major_version" {
+  description = "Major PostgreSQL version of the server, for privileges that differ between versions"
+  type        = number
+  default     = 15
+}
+
+variable "databases" {
+  description = "Map of database key to its name and managed schemas (the structure the root module renders into the permission script)"
+  type = map(object({
+    name = string
+    schemas = map(object({
+      owner = optional(string)
+    }))
+    creators = optional(list(string), []) # Roles whose future objects get the group default privileges
+    extensions = optional(map(object({
+      version = optional(string)
+      schema  = optional(string)
+      cascade = optional(bool, false)
+    })), {})
+  }))
+}
+
+variable "groups" {
+  description = "Map of database key to the NOLOGIN group roles holding privileges in it"
+  type = map(list(object({
+    name    = string
+    level   = string
+    schemas = list(string)
+  })))
+}
+
+variable "memberships" {
+  description = "Map of login role to the group roles it is a member of"
+  type        = map(list(string))
+  default     = {}
+}
+
+variable "admins" {
+  description = "Login roles that receive pg_read_all_data and pg_write_all_data (PostgreSQL 14 and later)"
+  type        = list(string)
+  default     = []
+}
+
+variable "grants" {
+  description = "Structured grants to individual login roles; objects is empty for every object of the type in the schema"
+  type = list(object({
+    role              = string
+    database          = string # Key in var.databases
+    schema            = string
+    object_type       = string # table, sequence, function or schema
+    objects           = list(string)
+    privileges        = list(string)
+    with_grant_option = bool
+  }))
+  default = []
+}
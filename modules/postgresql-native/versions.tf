@@ -0,0 +1,13 @@
+# Terraform and provider version constraints
+# Define required versions for Terraform and providers
+
+terraform {
+  required_version = ">= 1.4"
+
+  required_providers {
+    postgresql = {
+      source  = "cyrilgdn/postgresql"
+      version = ">= 1.21"
+    }
+  }
+}
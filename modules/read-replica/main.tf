@@ -0,0 +1,101 @@
+/**
+ * # Cloud SQL PostgreSQL Cascading Read Replica
+ *
+ * A single cascading read replica of the parent module, replicating from another replica. The parent
+ * creates the replicas of the primary itself and calls this module once per cascading level, so that
+ * cascading replicas are created after the replica they replicate from.
+ */
+
+resource "google_sql_database_instance" "replica" {
+  name                 = var.name
+  database_version     = var.database_version
+  region               = var.region
+  deletion_protection  = var.deletion_protection
+  project              = var.project_id
+  master_instance_name = var.master_instance_name
+
+  replica_configuration {
+    failover_target = var.failover_target
+  }
+
+  settings {
+    tier              = var.settings.tier
+    edition           = var.settings.edition
+    disk_type         = var.settings.disk_type
+    disk_size         = var.settings.disk_size
+    disk_autoresize   = var.settings.disk_autoresize
+    availability_type = var.settings.availability_type
+
+    disk_autoresize_limit = var.settings.disk_autoresize_limit
+
+    # Replicas do not take backups, but keep the location for use after promotion
+    dynamic "backup_configuration" {
+      for_each = var.backup_location != null ? [1] : []
+      content {
+        enabled  = false
+        location = var.backup_location
+      }
+    }
+
+    ip_configuration {
+      ipv4_enabled    = var.settings.ipv4_enabled
+      private_network = var.settings.private_network
+      ssl_mode        = var.settings.ssl_mode
+
+      dynamic "authorized_networks" {
+        for_each = var.settings.authorized_networks
+        content {
+          name  = authorized_networks.value.name
+          value = authorized_networks.value.cidr
+        }
+      }
+    }
+
+    maintenance_window {
+      day          = var.settings.maintenance_window.day
+      hour         = var.settings.maintenance_window.hour
+      update_track = var.settings.maintenance_window.update_track
+    }
+
+    insights_config {
+      query_insights_enabled  = var.settings.insights_config.query_insights_enabled
+      query_string_length     = var.settings.insights_config.query_string_length
+      record_application_tags = var.settings.insights_config.record_application_tags
+      record_client_address   = var.settings.insights_config.record_client_address
+      query_plans_per_minute  = var.settings.insights_config.query_plans_per_minute
+    }
+
+    # Data cache for Enterprise Plus
+    dynamic "data_cache_config" {
+      for_each = var.settings.edition == "ENTERPRISE_PLUS" && var.settings.data_cache_enabled ? [1] : []
+      content {
+        data_cache_enabled = true
+      }
+    }
+
+    user_labels = var.settings.labels
+
+    connector_enforcement = var.settings.connector_enforcement
+
+    dynamic "database_flags" {
+      for_each = merge(
+        {
+          # Read replica specific flags
+          hot_standby_feedback        = "on"
+          max_standby_streaming_delay = "30s"
+        },
+        var.settings.database_flags
+      )
+      content {
+        name  = database_flags.key
+        value = database_flags.value
+      }
+    }
+  }
+
+  timeouts {
+    create = var.timeouts.create
+    update = var.timeouts.update
+    delete = var.timeouts.delete
+  }
+}
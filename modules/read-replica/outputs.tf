@@ -0,0 +1,34 @@
+output "name" {
+  description = "The name of the replica instance"
+  value       = google_sql_database_instance.replica.name
+}
+
+output "connection_name" {
+  description = "The connection name of the replica (project:region:instance)"
+  value       = google_sql_database_instance.replica.connection_name
+}
+
+output "region" {
+  description = "The region of the replica"
+  value       = google_sql_database_instance.replica.region
+}
+
+output "master_instance_name" {
+  description = "The replica this replica replicates from"
+  value       = google_sql_database_instance.replica.master_instance_name
+}
+
+output "public_ip_address" {
+  description = "The public IPv4 address of the replica"
+  value       = try(google_sql_database_instance.replica.public_ip_address, null)
+}
+
+output "private_ip_address" {
+  description = "The private IP address of the replica"
+  value       = try(google_sql_database_instance.replica.private_ip_address, null)
+}
+
+output "service_account_email_address" {
+  description = "The service account email of the replica"
+  value       = google_sql_database_instance.replica.service_account_email_address
+}
@@ -0,0 +1,96 @@
+# ==========================================
+# REPLICA
+# ==========================================
+
+variable "name" {
+  description = "Name of the replica instance"
+  type        = string
+}
+
+variable "project_id" {
+  description = "The GCP project ID of the replica"
+  type        = string
+}
+
+variable "region" {
+  description = "The GCP region of the replica"
+  type        = string
+}
+
+variable "database_version" {
+  description = "PostgreSQL version, the same as the primary instance"
+  type        = string
+}
+
+variable "master_instance_name" {
+  description = "Replica the cascading replica replicates from"
+  type        = string
+}
+
+variable "deletion_protection" {
+  description = "Enable Terraform deletion protection"
+  type        = bool
+  default     = false
+}
+
+variable "failover_target" {
+  description = "Make the replica a failover target"
+  type        = bool
+  default     = false
+}
+
+# ==========================================
+# SETTINGS
+# ==========================================
+
+variable "settings" {
+  description = "Instance settings, resolved by the parent module from the replica overrides and the primary's settings"
+  type = object({
+    tier                  = string
+    edition               = string
+    disk_type             = string
+    disk_size             = number
+    disk_autoresize       = bool
+    disk_autoresize_limit = number
+    availability_type     = string
+    ipv4_enabled          = bool
+    private_network       = optional(string)
+    ssl_mode              = string
+    authorized_networks = list(object({
+      name = string
+      cidr = string
+    }))
+    maintenance_window = object({
+      day          = number
+      hour         = number
+      update_track = string
+    })
+    insights_config = object({
+      query_insights_enabled  = bool
+      query_string_length     = number
+      record_application_tags = bool
+      record_client_address   = bool
+      query_plans_per_minute  = number
+    })
+    data_cache_enabled    = bool
+    labels                = map(string)
+    connector_enforcement = string
+    database_flags        = map(string)
+  })
+}
+
+variable "backup_location" {
+  description = "Backup location of the primary, kept for use after promotion"
+  type        = string
+  default     = null
+}
+
+variable "timeouts" {
+  description = "Timeout configurations for resource operations"
+  type = object({
+    create = optional(string, "30m")
+    update = optional(string, "30m")
+    delete = optional(string, "30m")
+  })
+  default = {}
+}
@@ -36,6 +36,13 @@ output "private_ip_address" {
   value       = try(google_sql_database_instance.postgres.private_ip_address, null)
 }
 
+output "write_endpoint" {
+  description = "The write endpoint DNS name of the replication cluster, which follows the instance holding the primary role"
+  value = local.dr_switchover_active ? (
+    try(google_sql_database_instance.read_replicas[local.dr_replica_key].replication_cluster[0].psa_write_endpoint, null)
+  ) : try(google_sql_database_instance.postgres.replication_cluster[0].psa_write_endpoint, null)
+}
+
 # ==========================================
 # DATABASE OUTPUTS
 # ==========================================
@@ -53,8 +60,8 @@ output "databases" {
 }
 
 output "database_names" {
-  description = "List of database names"
-  value       = [for db in google_sql_database.databases : db.name]
+  description = "List of database names, including databases copied from a clone source or migration source"
+  value       = concat([for db in google_sql_database.databases : db.name], local.cloned_database_names, local.migrated_database_names)
 }
 
 # ==========================================
@@ -94,11 +101,11 @@ output "user_secret_ids" {
 # ==========================================
 
 output "connection_strings" {
-  description = "PostgreSQL connection strings for different scenarios"
+  description = "PostgreSQL connection strings for different scenarios, pointing at the instance holding the primary role"
   value = {
     public_ip = var.ipv4_enabled ? {
       for user_name in keys(var.users) :
-      user_name => "postgresql://${user_name}:<PASSWORD>@${google_sql_database_instance.postgres.public_ip_address}:5432/<DATABASE>?sslmode=require"
+      user_name => "postgresql://${user_name}:<PASSWORD>@${local.current_primary.public_ip_address}:5432/<DATABASE>?sslmode=require"
     } : {}
 
     cloud_sql_proxy = {
@@ -108,14 +115,40 @@ output "connection_strings" {
 
     psql_commands = {
       for user_name in keys(var.users) :
-      user_name => "PGPASSWORD=<PASSWORD> psql -h ${google_sql_database_instance.postgres.public_ip_address} -U ${user_name} -d <DATABASE>"
+      user_name => "PGPASSWORD=<PASSWORD> psql -h ${local.current_primary.public_ip_address} -U ${user_name} -d <DATABASE>"
+    }
+
+    # Pooled connections: managed pooling listens on port 6432 of the instance, PgBouncer runs next to the application
+    pooled = var.connection_pooling.enabled ? {
+      for user_name in keys(var.users) :
+      user_name => local.managed_connection_pooling ? (
+        "postgresql://${user_name}:<PASSWORD>@${coalesce(local.current_primary.private_ip_address, local.current_primary.public_ip_address)}:6432/<DATABASE>?sslmode=require"
+      ) : "postgresql://${user_name}:<PASSWORD>@<PGBOUNCER_HOST>:${var.connection_pooling.listen_port}/<DATABASE>"
+    } : {}
+
+    # Load-balanced read endpoint of each read pool
+    read_pools = {
+      for pool_name, pool in google_sql_database_instance.read_pools :
+      pool_name => {
+        for user_name in keys(var.users) :
+        user_name => "postgresql://${user_name}:<PASSWORD>@${coalesce(pool.private_ip_address, pool.public_ip_address, "<READ_POOL_IP>")}:5432/<DATABASE>?sslmode=require"
+      }
     }
   }
 }
 
+output "connection_pooling" {
+  description = "Connection pooling mode and generated PgBouncer files"
+  value = {
+    mode             = local.managed_connection_pooling ? "managed" : (local.pgbouncer_enabled ? "pgbouncer" : "disabled")
+    pgbouncer_config = local.pgbouncer_enabled ? local_file.pgbouncer_config[0].filename : null
+    pgbouncer_users  = local.pgbouncer_enabled ? local_sensitive_file.pgbouncer_userlist[0].filename : null
+  }
+}
+
 output "cloud_sql_proxy_command" {
-  description = "Command to start Cloud SQL proxy for PostgreSQL"
-  value       = "cloud-sql-proxy --port=5432 ${google_sql_database_instance.postgres.connection_name}"
+  description = "Command to start Cloud SQL proxy for PostgreSQL, pointing at the instance holding the primary role"
+  value       = "cloud-sql-proxy --port=5432 ${local.current_primary.connection_name}"
 }
 
 # ==========================================
@@ -123,19 +156,92 @@ output "cloud_sql_proxy_command" {
 # ==========================================
 
 output "read_replicas" {
-  description = "Map of read replica information"
+  description = "Map of read replica information, including cascading replicas"
+  value = {
+    for k, v in merge(
+      {
+        for name, replica in google_sql_database_instance.read_replicas : name => {
+          name                 = replica.name
+          connection_name      = replica.connection_name
+          public_ip_address    = try(replica.public_ip_address, null)
+          private_ip_address   = try(replica.private_ip_address, null)
+          region               = replica.region
+          master_instance_name = replica.master_instance_name
+        }
+      },
+      module.read_replicas_level2,
+      module.read_replicas_level3
+    ) :
+    k => {
+      name               = v.name
+      connection_name    = v.connection_name
+      public_ip_address  = v.public_ip_address
+      private_ip_address = v.private_ip_address
+      region             = v.region
+      disaster_recovery  = k == local.dr_replica_key
+      source             = v.master_instance_name
+      level              = local.read_replica_levels[k]
+    }
+  }
+}
+
+output "replication_cluster" {
+  description = "Disaster recovery replication cluster roles, showing which instance currently holds the primary role"
+  value = {
+    dr_replica_key        = local.dr_replica_key
+    switchover_active     = local.dr_switchover_active
+    primary_instance_name = local.current_primary_instance_name
+    dr_instance_name      = local.dr_switchover_active ? local.instance_name : local.dr_replica_instance_name
+  }
+}
+
+# ==========================================
+# READ POOL OUTPUTS
+# ==========================================
+
+output "read_pools" {
+  description = "Map of read pool information with the single read endpoint of each pool"
   value = {
-    for k, v in google_sql_database_instance.read_replicas :
+    for k, v in google_sql_database_instance.read_pools :
     k => {
       name               = v.name
       connection_name    = v.connection_name
+      node_count         = v.node_count
       public_ip_address  = try(v.public_ip_address, null)
       private_ip_address = try(v.private_ip_address, null)
-      region             = v.region
+      dns_name           = try(v.dns_name, null)
     }
   }
 }
 
+# ==========================================
+# EXPORT OUTPUTS
+# ==========================================
+
+output "exports" {
+  description = "Scheduled logical export bucket and Cloud Scheduler jobs"
+  value = var.exports.enabled ? {
+    bucket_name               = google_storage_bucket.exports[0].name
+    bucket_url                = google_storage_bucket.exports[0].url
+    scheduler_jobs            = { for k, v in google_cloud_scheduler_job.exports : k => v.name }
+    scheduler_service_account = local.exports_scheduler_service_account
+  } : null
+}
+
+# ==========================================
+# MIGRATION OUTPUTS
+# ==========================================
+
+output "migration" {
+  description = "Database Migration Service job name, state and phase"
+  value = var.migration.enabled ? {
+    job_name = google_database_migration_service_migration_job.migration[0].name
+    state    = google_database_migration_service_migration_job.migration[0].state
+    phase    = google_database_migration_service_migration_job.migration[0].phase
+    promoted = var.migration.promote
+  } : null
+}
+
 # ==========================================
 # CONFIGURATION OUTPUTS
 # ==========================================
@@ -153,7 +259,27 @@ output "configuration" {
     region            = var.region
     availability_type = var.availability_type
     backup_enabled    = var.backup_enabled
-    preset_used       = var.use_preset_config
+    recovery_window = {
+      retained_backups            = var.backup_enabled ? local.retained_backups : 0
+      point_in_time_recovery_days = var.backup_enabled && var.point_in_time_recovery ? var.transaction_log_retention_days : 0
+      backup_dr_retention_days    = var.backup_dr.enabled ? var.backup_dr.retention_days : 0
+    }
+    preset_used  = var.use_preset_config
+    clone_mode   = local.clone_mode
+    clone_source = try(var.clone_from.source_instance_name, null)
+    deletion_safeguards = {
+      deletion_protection         = local.deletion_protection
+      deletion_protection_enabled = local.deletion_protection_enabled
+      retain_backups_on_delete    = local.retain_backups_on_delete
+      final_backup_enabled        = local.final_backup_enabled
+      final_backup_retention_days = local.final_backup_enabled ? var.final_backup.retention_days : null
+    }
+    backup_dr = var.backup_dr.enabled ? {
+      backup_vault_id     = local.backup_dr_vault
+      backup_plan_id      = google_backup_dr_backup_plan.plan[0].id
+      retention_days      = var.backup_dr.retention_days
+      plan_association_id = google_backup_dr_backup_plan_association.postgres[0].id
+    } : null
   }
 }
 
@@ -161,7 +287,47 @@ output "permission_scripts" {
   description = "Generated permission setup scripts"
   value = {
     permissions = var.generate_permission_script ? local_file.permission_script[0].filename : null
-    extensions  = length(var.postgresql_extensions) > 0 ? local_file.extensions_script[0].filename : null
+    rollback    = var.generate_permission_script ? local_file.rollback_script[0].filename : null
+    deprovision = length(var.deprovisioned_users) > 0 ? local_file.deprovision_script[0].filename : null
+    extensions  = length(local_file.extensions_script) > 0 ? local_file.extensions_script[0].filename : null
+    cron_jobs   = length(var.cron_jobs) > 0 ? local_file.cron_jobs_script[0].filename : null
+  }
+}
+
+output "permission_model" {
+  description = "Databases, group roles, memberships and grants of the permission script, in the form modules/postgresql-native takes them"
+  value = {
+    databases = {
+      for db_name, db_schemas in local.database_schemas :
+      db_name => {
+        name       = try(google_sql_database.databases[db_name].name, db_name)
+        schemas    = db_schemas
+        creators   = local.database_creators[db_name]
+        extensions = local.database_extensions[db_name]
+      }
+    }
+    groups = local.permission_groups
+    memberships = {
+      for user_name, group_names in local.user_group_memberships :
+      try(google_sql_user.users[user_name].name, user_name) => group_names if !contains(keys(var.deprovisioned_users), user_name)
+    }
+    admins        = [for user_name in local.unscoped_admins : try(google_sql_user.users[user_name].name, user_name)]
+    major_version = local.postgres_major_version
+    grants = flatten([
+      for user_name, db_grants in local.user_custom_grants : [
+        for db_name, grants in db_grants : [
+          for grant in grants.grants : {
+            role              = try(google_sql_user.users[user_name].name, user_name)
+            database          = db_name
+            schema            = grant.schema
+            object_type       = lower(grant.object_type)
+            objects           = grant.objects
+            privileges        = grant.privileges
+            with_grant_option = grant.with_grant_option
+          }
+        ]
+      ]
+    ])
   }
 }
 
@@ -187,7 +353,7 @@ output "postgres_info" {
   description = "PostgreSQL-specific configuration information"
   value = {
     version    = var.postgres_version
-    extensions = var.postgresql_extensions
+    extensions = local.database_extensions
     performance_flags = var.auto_generate_performance_flags ? {
       shared_buffers       = local.postgres_performance_flags["shared_buffers"]
       effective_cache_size = local.postgres_performance_flags["effective_cache_size"]
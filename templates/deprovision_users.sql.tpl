@@ -0,0 +1,83 @@
+-- PostgreSQL User Deprovisioning Script
+-- Generated by Terraform
+-- Run this script as the postgres user before the users are deleted. Cloud SQL has no superuser, so the script
+-- grants the current user membership in the roles whose objects it moves and revokes it again afterwards.
+-- Every database section runs in its own transaction and the script can be re-run after a partial failure
+
+\set ON_ERROR_STOP on
+
+%{ for user_name, user_config in users ~}
+-- ==========================================
+-- USER: ${user_name}
+-- ==========================================
+
+%{ for db_name, db in databases ~}
+-- Database: ${db_name}
+\c ${literal[db_name]}
+BEGIN;
+DO $$
+DECLARE
+  role_name text;
+  granted text[] := '{}';
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = ${literal[user_name]}) THEN
+    RAISE WARNING 'Role % does not exist, nothing to deprovision in %', ${literal[user_name]}, ${literal[db_name]};
+    RETURN;
+  END IF;
+  -- Reassigning and dropping objects needs membership in the user and in the role taking over its objects
+  FOR role_name IN
+    SELECT rolname FROM pg_roles
+    WHERE rolname IN (${literal[user_name]}, ${literal[reassign_to[user_name][db_name]]})
+      AND NOT pg_has_role(current_user, oid, 'MEMBER')
+  LOOP
+    EXECUTE format('GRANT %I TO %I', role_name, current_user);
+    granted := granted || role_name;
+  END LOOP;
+  REASSIGN OWNED BY ${ident[user_name]} TO ${ident[reassign_to[user_name][db_name]]};
+  DROP OWNED BY ${ident[user_name]};
+  REVOKE ALL PRIVILEGES ON DATABASE ${ident[db_name]} FROM ${ident[user_name]};
+%{ for schema_name in keys(schemas[db_name]) ~}
+  IF EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = ${literal[schema_name]}) THEN
+    REVOKE ALL PRIVILEGES ON SCHEMA ${ident[schema_name]} FROM ${ident[user_name]};
+    REVOKE ALL PRIVILEGES ON ALL TABLES IN SCHEMA ${ident[schema_name]} FROM ${ident[user_name]};
+    REVOKE ALL PRIVILEGES ON ALL SEQUENCES IN SCHEMA ${ident[schema_name]} FROM ${ident[user_name]};
+    REVOKE ALL PRIVILEGES ON ALL FUNCTIONS IN SCHEMA ${ident[schema_name]} FROM ${ident[user_name]};
+  END IF;
+%{ endfor ~}
+  FOREACH role_name IN ARRAY granted LOOP
+    EXECUTE format('REVOKE %I FROM %I', role_name, current_user);
+  END LOOP;
+END $$;
+COMMIT;
+\echo 'Deprovisioned' ${literal[user_name]} 'in database' ${literal[db_name]}
+
+%{ endfor ~}
+\c postgres
+BEGIN;
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = ${literal[user_name]}) THEN
+    RETURN;
+  END IF;
+%{ for group_name in groups ~}
+  IF EXISTS (SELECT 1 FROM pg_roles WHERE rolname = ${literal[group_name]}) THEN
+    REVOKE ${ident[group_name]} FROM ${ident[user_name]};
+  END IF;
+%{ endfor ~}
+END $$;
+COMMIT;
+\echo 'Group memberships revoked for' ${literal[user_name]}
+
+%{ endfor ~}
+-- ==========================================
+-- SUMMARY
+-- ==========================================
+
+\c postgres
+
+SELECT
+    r.rolname as username,
+    ARRAY(SELECT b.rolname FROM pg_auth_members m JOIN pg_roles b ON m.roleid = b.oid WHERE m.member = r.oid) as member_of
+FROM pg_roles r
+WHERE r.rolname IN (${join(", ", [for user_name in keys(users) : literal[user_name]])})
+ORDER BY r.rolname;
@@ -0,0 +1,30 @@
+;; PgBouncer Configuration
+;; Generated by Terraform for Cloud SQL instance ${instance}
+;; Run PgBouncer next to the application and point clients at listen_port
+
+;; ==========================================
+;; DATABASES
+;; ==========================================
+
+[databases]
+%{ for db_name in keys(databases) ~}
+${db_name} = host=${host} port=5432 dbname=${db_name}
+%{ endfor ~}
+
+;; ==========================================
+;; POOLER SETTINGS
+;; ==========================================
+
+[pgbouncer]
+listen_addr = 0.0.0.0
+listen_port = ${listen_port}
+
+auth_type = scram-sha-256
+auth_file = ${userlist}
+
+pool_mode           = ${pooling.pool_mode}
+default_pool_size   = ${pooling.pool_size}
+max_client_conn     = ${pooling.max_client_connections}
+client_idle_timeout = ${pooling.client_idle_timeout}
+
+server_tls_sslmode = ${server_tls}
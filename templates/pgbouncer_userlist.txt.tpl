@@ -0,0 +1,3 @@
+%{ for user_name, password in passwords ~}
+"${user_name}" "${replace(password, "\"", "\"\"")}"
+%{ endfor ~}
@@ -0,0 +1,135 @@
+-- PostgreSQL Permission Rollback Script
+-- Generated by Terraform
+-- Reverses setup_postgres_permissions.sql; run it as the postgres user, like the setup script
+-- Schemas and ownership changes are kept, since dropping them could lose data
+-- Raw grants statements are not reversed
+
+\set ON_ERROR_STOP on
+
+-- ==========================================
+-- USER ROLE CONFIGURATION
+-- ==========================================
+
+\c postgres
+BEGIN;
+
+%{ for user_name, group_names in memberships ~}
+-- User: ${user_name}
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = ${literal[user_name]}) THEN
+    RETURN;
+  END IF;
+%{ if contains(admins, user_name) ~}
+  ALTER USER ${ident[user_name]} NOCREATEDB NOCREATEROLE;
+%{ if major_version >= 14 ~}
+  REVOKE pg_read_all_data FROM ${ident[user_name]};
+  REVOKE pg_write_all_data FROM ${ident[user_name]};
+%{ endif ~}
+%{ endif ~}
+%{ for group_name in group_names ~}
+  IF EXISTS (SELECT 1 FROM pg_roles WHERE rolname = ${literal[group_name]}) THEN
+    REVOKE ${ident[group_name]} FROM ${ident[user_name]};
+  END IF;
+%{ endfor ~}
+END $$;
+
+%{ endfor ~}
+COMMIT;
+\echo 'Group memberships revoked'
+
+-- ==========================================
+-- CUSTOM GRANTS
+-- ==========================================
+
+%{ for user_name, db_grants in custom_grants ~}
+%{ for db_name, grants in db_grants ~}
+%{ if length(grants.grants) > 0 ~}
+-- User: ${user_name}
+\c ${literal[db_name]}
+BEGIN;
+%{ for grant in grants.grants ~}
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = ${literal[user_name]}) OR NOT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = ${literal[grant.schema]}) THEN
+    RETURN;
+  END IF;
+%{ if grant.object_type == "SCHEMA" ~}
+  REVOKE ${join(", ", grant.privileges)} ON SCHEMA ${ident[grant.schema]} FROM ${ident[user_name]};
+%{ endif ~}
+%{ if grant.object_type != "SCHEMA" && length(grant.objects) == 0 ~}
+  REVOKE ${join(", ", grant.privileges)} ON ALL ${grant.object_type}S IN SCHEMA ${ident[grant.schema]} FROM ${ident[user_name]};
+%{ endif ~}
+%{ if grant.object_type != "SCHEMA" && length(grant.objects) > 0 ~}
+  REVOKE ${join(", ", grant.privileges)} ON ${grant.object_type} ${join(", ", [for object in grant.objects : "${ident[grant.schema]}.${ident[object]}"])} FROM ${ident[user_name]};
+%{ endif ~}
+EXCEPTION WHEN undefined_table OR undefined_function THEN
+  RAISE WARNING 'Objects granted to % no longer exist, skipping', ${literal[user_name]};
+END $$;
+%{ endfor ~}
+COMMIT;
+\echo 'Custom grants revoked for' ${literal[user_name]} 'in' ${literal[db_name]}
+
+%{ endif ~}
+%{ endfor ~}
+%{ endfor ~}
+-- ==========================================
+-- GROUP ROLES
+-- ==========================================
+
+%{ for db_name, db_groups in groups ~}
+-- Database: ${db_name}
+\c ${literal[db_name]}
+BEGIN;
+%{ for group in db_groups ~}
+DO $$
+DECLARE
+  granted boolean;
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = ${literal[group.name]}) THEN
+    RETURN;
+  END IF;
+%{ for schema_name in group.schemas ~}
+  IF EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = ${literal[schema_name]}) THEN
+    ALTER DEFAULT PRIVILEGES IN SCHEMA ${ident[schema_name]} REVOKE ALL ON TABLES FROM ${ident[group.name]};
+    ALTER DEFAULT PRIVILEGES IN SCHEMA ${ident[schema_name]} REVOKE ALL ON SEQUENCES FROM ${ident[group.name]};
+    ALTER DEFAULT PRIVILEGES IN SCHEMA ${ident[schema_name]} REVOKE ALL ON FUNCTIONS FROM ${ident[group.name]};
+%{ for creator in creators[db_name] ~}
+    IF EXISTS (SELECT 1 FROM pg_roles WHERE rolname = ${literal[creator]}) THEN
+      granted := NOT pg_has_role(current_user, ${literal[creator]}, 'MEMBER');
+      IF granted THEN
+        GRANT ${ident[creator]} TO CURRENT_USER;
+      END IF;
+      ALTER DEFAULT PRIVILEGES FOR ROLE ${ident[creator]} IN SCHEMA ${ident[schema_name]} REVOKE ALL ON TABLES FROM ${ident[group.name]};
+      ALTER DEFAULT PRIVILEGES FOR ROLE ${ident[creator]} IN SCHEMA ${ident[schema_name]} REVOKE ALL ON SEQUENCES FROM ${ident[group.name]};
+      ALTER DEFAULT PRIVILEGES FOR ROLE ${ident[creator]} IN SCHEMA ${ident[schema_name]} REVOKE ALL ON FUNCTIONS FROM ${ident[group.name]};
+      IF granted THEN
+        REVOKE ${ident[creator]} FROM CURRENT_USER;
+      END IF;
+    END IF;
+%{ endfor ~}
+    REVOKE ALL PRIVILEGES ON ALL TABLES IN SCHEMA ${ident[schema_name]} FROM ${ident[group.name]};
+    REVOKE ALL PRIVILEGES ON ALL SEQUENCES IN SCHEMA ${ident[schema_name]} FROM ${ident[group.name]};
+    REVOKE ALL PRIVILEGES ON ALL FUNCTIONS IN SCHEMA ${ident[schema_name]} FROM ${ident[group.name]};
+    REVOKE ALL PRIVILEGES ON SCHEMA ${ident[schema_name]} FROM ${ident[group.name]};
+  END IF;
+%{ endfor ~}
+  REVOKE ALL PRIVILEGES ON DATABASE ${ident[db_name]} FROM ${ident[group.name]};
+END $$;
+%{ endfor ~}
+%{ if major_version < 15 ~}
+-- Restore the default CREATE privilege on public of PostgreSQL versions before 15
+GRANT CREATE ON SCHEMA ${ident["public"]} TO PUBLIC;
+%{ endif ~}
+COMMIT;
+\echo 'Group privileges revoked in database' ${literal[db_name]}
+
+%{ endfor ~}
+\c postgres
+
+%{ for db_groups in values(groups) ~}
+%{ for group in db_groups ~}
+DROP ROLE IF EXISTS ${ident[group.name]};
+%{ endfor ~}
+%{ endfor ~}
+\echo 'Group roles dropped'
@@ -0,0 +1,35 @@
+-- pg_cron Job Setup Script
+-- Generated by Terraform
+-- Run this script as the postgres superuser after the extensions script
+-- Jobs are created or updated by name; named jobs that are no longer declared are unscheduled
+
+\set ON_ERROR_STOP on
+
+\c ${literal[cron_database]}
+BEGIN;
+
+CREATE EXTENSION IF NOT EXISTS pg_cron;
+
+-- ==========================================
+-- SCHEDULED JOBS
+-- ==========================================
+
+%{ for job_name, job in jobs ~}
+-- Job: ${job_name}
+SELECT cron.schedule_in_database(${literal[job_name]}, ${literal[job.schedule]}, ${literal[job.command]}, ${literal[job.database]}, ${job.username != null ? literal[job.username] : "NULL"}, ${job.active});
+%{ endfor ~}
+
+-- ==========================================
+-- REMOVED JOBS
+-- ==========================================
+
+SELECT cron.unschedule(jobid)
+FROM cron.job
+WHERE jobname IS NOT NULL
+  AND jobname <> ALL (ARRAY[${join(", ", [for job_name in keys(jobs) : literal[job_name]])}]::text[]);
+
+COMMIT;
+\echo 'Cron jobs scheduled in database' ${literal[cron_database]}
+
+-- List scheduled jobs
+SELECT jobid, jobname, schedule, database, username, active FROM cron.job ORDER BY jobname;
@@ -1,22 +1,39 @@
 -- PostgreSQL Extensions Setup Script
 -- Generated by Terraform
 -- Run this script as the postgres superuser after deployment
+-- Every database section runs in its own transaction and the script can be re-run after a partial failure
+
+\set ON_ERROR_STOP on
 
 -- ==========================================
 -- ENABLE EXTENSIONS
 -- ==========================================
 
-%{ for db_name in keys(databases) ~}
+%{ for db_name, db_extensions in extensions ~}
+%{ if length(db_extensions) > 0 ~}
 -- Database: ${db_name}
-\c ${db_name}
-
-%{ for extension in extensions ~}
-CREATE EXTENSION IF NOT EXISTS ${extension};
+\c ${literal[db_name]}
+BEGIN;
+
+%{ for name, extension in db_extensions ~}
+CREATE EXTENSION IF NOT EXISTS ${ident[name]}${extension.schema != null ? " SCHEMA ${ident[extension.schema]}" : ""}${extension.version != null ? " VERSION ${literal[extension.version]}" : ""}${extension.cascade ? " CASCADE" : ""};
+%{ if extension.version != null ~}
+DO $$
+BEGIN
+  IF (SELECT extversion FROM pg_extension WHERE extname = ${literal[name]}) IS DISTINCT FROM ${literal[extension.version]} THEN
+    ALTER EXTENSION ${ident[name]} UPDATE TO ${literal[extension.version]};
+  END IF;
+END $$;
+%{ endif ~}
 %{ endfor ~}
 
+COMMIT;
+\echo 'Extensions enabled in database' ${literal[db_name]}
+
 -- List enabled extensions
 SELECT extname, extversion FROM pg_extension ORDER BY extname;
 
+%{ endif ~}
 %{ endfor ~}
 
 -- ==========================================
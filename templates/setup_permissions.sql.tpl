@@ -1,82 +1,240 @@
 -- PostgreSQL Permission Setup Script
 -- Generated by Terraform
--- Run this script as the postgres superuser after deployment
+-- Run this script as the postgres user after deployment. Cloud SQL has no superuser, so the script grants the
+-- current user membership in the roles whose objects it changes and revokes it again afterwards.
+-- Every database section runs in its own transaction and the script can be re-run after a partial failure
+
+\set ON_ERROR_STOP on
 
 -- ==========================================
--- USER ROLE CONFIGURATION
+-- DATABASES
 -- ==========================================
 
-%{ for user_name, user_config in users ~}
--- User: ${user_name}
--- Role: ${try(user_config.role, "custom")}
-
-%{ if try(user_config.role, "custom") == "admin" ~}
--- Grant admin privileges
-ALTER USER ${user_name} CREATEDB CREATEROLE;
-GRANT pg_read_all_data TO ${user_name};
-GRANT pg_write_all_data TO ${user_name};
-
-%{ for db_name in keys(databases) ~}
--- Grant all privileges on database ${db_name}
-GRANT ALL PRIVILEGES ON DATABASE ${db_name} TO ${user_name};
-\c ${db_name}
-GRANT ALL PRIVILEGES ON SCHEMA public TO ${user_name};
-GRANT ALL PRIVILEGES ON ALL TABLES IN SCHEMA public TO ${user_name};
-GRANT ALL PRIVILEGES ON ALL SEQUENCES IN SCHEMA public TO ${user_name};
-GRANT ALL PRIVILEGES ON ALL FUNCTIONS IN SCHEMA public TO ${user_name};
-ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT ALL ON TABLES TO ${user_name};
-ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT ALL ON SEQUENCES TO ${user_name};
-ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT ALL ON FUNCTIONS TO ${user_name};
+%{ for db_name, db_schemas in schemas ~}
+-- Database: ${db_name}
+\c ${literal[db_name]}
+BEGIN;
+
+%{ if databases[db_name].owner != null ~}
+-- Ownership
+DO $$
+DECLARE
+  role_name text;
+  granted text[] := '{}';
+BEGIN
+  IF EXISTS (SELECT 1 FROM pg_roles WHERE rolname = ${literal[databases[db_name].owner]}) THEN
+    -- Changing the owner and reassigning objects needs membership in the current owner and the roles involved
+    FOR role_name IN
+      SELECT rolname FROM pg_roles
+      WHERE (rolname IN (${join(", ", [for role in concat([databases[db_name].owner], databases[db_name].reassign_owned_from) : literal[role]])})
+          OR oid = (SELECT datdba FROM pg_database WHERE datname = current_database()))
+        AND NOT pg_has_role(current_user, oid, 'MEMBER')
+    LOOP
+      EXECUTE format('GRANT %I TO %I', role_name, current_user);
+      granted := granted || role_name;
+    END LOOP;
+    ALTER DATABASE ${ident[db_name]} OWNER TO ${ident[databases[db_name].owner]};
+%{ for role in databases[db_name].reassign_owned_from ~}
+    IF EXISTS (SELECT 1 FROM pg_roles WHERE rolname = ${literal[role]}) THEN
+      REASSIGN OWNED BY ${ident[role]} TO ${ident[databases[db_name].owner]};
+    END IF;
+%{ endfor ~}
+    FOREACH role_name IN ARRAY granted LOOP
+      EXECUTE format('REVOKE %I FROM %I', role_name, current_user);
+    END LOOP;
+  ELSE
+    RAISE WARNING 'Role % does not exist, database % keeps its owner', ${literal[databases[db_name].owner]}, ${literal[db_name]};
+  END IF;
+END $$;
 
+%{ endif ~}
+-- Schemas
+%{ for schema_name, schema in db_schemas ~}
+%{ if schema_name != "public" ~}
+CREATE SCHEMA IF NOT EXISTS ${ident[schema_name]};
+%{ endif ~}
+%{ if schema.owner != null ~}
+DO $$
+DECLARE
+  role_name text;
+  granted text[] := '{}';
+BEGIN
+  IF EXISTS (SELECT 1 FROM pg_roles WHERE rolname = ${literal[schema.owner]}) THEN
+    -- pg_database_owner owns public on PostgreSQL 15 and later; membership in the database owner covers it
+    FOR role_name IN
+      SELECT rolname FROM pg_roles
+      WHERE (rolname = ${literal[schema.owner]}
+          OR oid = (SELECT nspowner FROM pg_namespace WHERE nspname = ${literal[schema_name]})
+          OR oid = (SELECT datdba FROM pg_database WHERE datname = current_database()))
+        AND rolname <> 'pg_database_owner'
+        AND NOT pg_has_role(current_user, oid, 'MEMBER')
+    LOOP
+      EXECUTE format('GRANT %I TO %I', role_name, current_user);
+      granted := granted || role_name;
+    END LOOP;
+    ALTER SCHEMA ${ident[schema_name]} OWNER TO ${ident[schema.owner]};
+    FOREACH role_name IN ARRAY granted LOOP
+      EXECUTE format('REVOKE %I FROM %I', role_name, current_user);
+    END LOOP;
+  ELSE
+    RAISE WARNING 'Role % does not exist, schema % keeps its owner', ${literal[schema.owner]}, ${literal[schema_name]};
+  END IF;
+END $$;
+%{ endif ~}
 %{ endfor ~}
+%{ if major_version < 15 ~}
+-- PostgreSQL 15 and later no longer let every role create objects in public; apply the same default here
+REVOKE CREATE ON SCHEMA ${ident["public"]} FROM PUBLIC;
+%{ endif ~}
 
+-- Group roles
+%{ for group in groups[db_name] ~}
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = ${literal[group.name]}) THEN
+    CREATE ROLE ${ident[group.name]} NOLOGIN;
+  END IF;
+END $$;
+%{ if group.level == "admin" ~}
+GRANT ALL PRIVILEGES ON DATABASE ${ident[db_name]} TO ${ident[group.name]};
 %{ else ~}
-%{ if try(user_config.role, "custom") == "readwrite" ~}
--- Grant read-write privileges
-%{ for db_name in keys(databases) ~}
-GRANT CONNECT ON DATABASE ${db_name} TO ${user_name};
-\c ${db_name}
-GRANT USAGE, CREATE ON SCHEMA public TO ${user_name};
-GRANT ALL PRIVILEGES ON ALL TABLES IN SCHEMA public TO ${user_name};
-GRANT ALL PRIVILEGES ON ALL SEQUENCES IN SCHEMA public TO ${user_name};
-GRANT EXECUTE ON ALL FUNCTIONS IN SCHEMA public TO ${user_name};
-ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT ALL ON TABLES TO ${user_name};
-ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT ALL ON SEQUENCES TO ${user_name};
-ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT EXECUTE ON FUNCTIONS TO ${user_name};
+GRANT CONNECT ON DATABASE ${ident[db_name]} TO ${ident[group.name]};
+%{ endif ~}
+%{ endfor ~}
 
+-- Group privileges
+%{ for group in groups[db_name] ~}
+%{ for schema_name in group.schemas ~}
+DO $$
+DECLARE
+  granted boolean;
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = ${literal[schema_name]}) THEN
+    RAISE WARNING 'Schema % does not exist, skipping ${group.level} privileges for %', ${literal[schema_name]}, ${literal[group.name]};
+    RETURN;
+  END IF;
+%{ if group.level == "admin" ~}
+  GRANT ALL PRIVILEGES ON SCHEMA ${ident[schema_name]} TO ${ident[group.name]};
+  GRANT ALL PRIVILEGES ON ALL TABLES IN SCHEMA ${ident[schema_name]} TO ${ident[group.name]};
+  GRANT ALL PRIVILEGES ON ALL SEQUENCES IN SCHEMA ${ident[schema_name]} TO ${ident[group.name]};
+  GRANT ALL PRIVILEGES ON ALL FUNCTIONS IN SCHEMA ${ident[schema_name]} TO ${ident[group.name]};
+%{ endif ~}
+%{ if group.level == "readwrite" ~}
+  GRANT USAGE, CREATE ON SCHEMA ${ident[schema_name]} TO ${ident[group.name]};
+  GRANT ALL PRIVILEGES ON ALL TABLES IN SCHEMA ${ident[schema_name]} TO ${ident[group.name]};
+  GRANT ALL PRIVILEGES ON ALL SEQUENCES IN SCHEMA ${ident[schema_name]} TO ${ident[group.name]};
+  GRANT EXECUTE ON ALL FUNCTIONS IN SCHEMA ${ident[schema_name]} TO ${ident[group.name]};
+%{ endif ~}
+%{ if group.level == "readonly" ~}
+  GRANT USAGE ON SCHEMA ${ident[schema_name]} TO ${ident[group.name]};
+  GRANT SELECT ON ALL TABLES IN SCHEMA ${ident[schema_name]} TO ${ident[group.name]};
+  GRANT SELECT ON ALL SEQUENCES IN SCHEMA ${ident[schema_name]} TO ${ident[group.name]};
+%{ endif ~}
+%{ for privilege in default_privileges[group.level] ~}
+  ALTER DEFAULT PRIVILEGES IN SCHEMA ${ident[schema_name]} GRANT ${privilege} TO ${ident[group.name]};
+%{ endfor ~}
+%{ for creator in creators[db_name] ~}
+  -- Objects created later by ${creator}; FOR ROLE needs membership in the creator
+  IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = ${literal[creator]}) THEN
+    RAISE WARNING 'Role % does not exist, skipping default privileges on its objects in schema %', ${literal[creator]}, ${literal[schema_name]};
+  ELSE
+    granted := NOT pg_has_role(current_user, ${literal[creator]}, 'MEMBER');
+    IF granted THEN
+      GRANT ${ident[creator]} TO CURRENT_USER;
+    END IF;
+%{ for privilege in default_privileges[group.level] ~}
+    ALTER DEFAULT PRIVILEGES FOR ROLE ${ident[creator]} IN SCHEMA ${ident[schema_name]} GRANT ${privilege} TO ${ident[group.name]};
+%{ endfor ~}
+    IF granted THEN
+      REVOKE ${ident[creator]} FROM CURRENT_USER;
+    END IF;
+  END IF;
+%{ endfor ~}
+END $$;
+%{ endfor ~}
 %{ endfor ~}
 
-%{ else ~}
-%{ if try(user_config.role, "custom") == "readonly" ~}
--- Grant read-only privileges
-%{ for db_name in keys(databases) ~}
-GRANT CONNECT ON DATABASE ${db_name} TO ${user_name};
-\c ${db_name}
-GRANT USAGE ON SCHEMA public TO ${user_name};
-GRANT SELECT ON ALL TABLES IN SCHEMA public TO ${user_name};
-GRANT SELECT ON ALL SEQUENCES IN SCHEMA public TO ${user_name};
-ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT ON TABLES TO ${user_name};
-ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT ON SEQUENCES TO ${user_name};
+COMMIT;
+\echo 'Ownership, schemas and group privileges applied in database' ${literal[db_name]}
 
 %{ endfor ~}
+-- ==========================================
+-- USER ROLE CONFIGURATION
+-- ==========================================
+
+\c postgres
+BEGIN;
 
+%{ for user_name, user_config in users ~}
+-- User: ${user_name}
+-- Role: ${user_config.role}
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = ${literal[user_name]}) THEN
+    RAISE WARNING 'Role % does not exist, skipping its memberships', ${literal[user_name]};
+    RETURN;
+  END IF;
+%{ if contains(admins, user_name) ~}
+  ALTER USER ${ident[user_name]} CREATEDB CREATEROLE;
+%{ if major_version >= 14 ~}
+  GRANT pg_read_all_data TO ${ident[user_name]};
+  GRANT pg_write_all_data TO ${ident[user_name]};
 %{ else ~}
--- Custom role with specific grants
-%{ for db_name, grants in try(user_config.custom_grants, {}) ~}
-\c ${db_name}
-%{ for grant in grants ~}
-${grant}
+  -- pg_read_all_data and pg_write_all_data need PostgreSQL 14; access comes from the admin group roles
+%{ endif ~}
+%{ endif ~}
+%{ for group_name in memberships[user_name] ~}
+  GRANT ${ident[group_name]} TO ${ident[user_name]};
 %{ endfor ~}
+END $$;
 
 %{ endfor ~}
+COMMIT;
+\echo 'Group memberships applied'
+
+%{ for user_name, db_grants in custom_grants ~}
+-- User: ${user_name}
+-- Custom role with specific grants
+%{ for db_name, grants in db_grants ~}
+\c ${literal[db_name]}
+BEGIN;
+%{ for grant in grants.grants ~}
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = ${literal[user_name]}) THEN
+    RAISE WARNING 'Role % does not exist, skipping its custom grants', ${literal[user_name]};
+    RETURN;
+  END IF;
+  IF NOT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = ${literal[grant.schema]}) THEN
+    RAISE WARNING 'Schema % does not exist, skipping custom grants for %', ${literal[grant.schema]}, ${literal[user_name]};
+    RETURN;
+  END IF;
+%{ if grant.object_type == "SCHEMA" ~}
+  GRANT ${join(", ", grant.privileges)} ON SCHEMA ${ident[grant.schema]} TO ${ident[user_name]}${grant.with_grant_option ? " WITH GRANT OPTION" : ""};
 %{ endif ~}
+%{ if grant.object_type != "SCHEMA" && length(grant.objects) == 0 ~}
+  GRANT ${join(", ", grant.privileges)} ON ALL ${grant.object_type}S IN SCHEMA ${ident[grant.schema]} TO ${ident[user_name]}${grant.with_grant_option ? " WITH GRANT OPTION" : ""};
 %{ endif ~}
+%{ if grant.object_type != "SCHEMA" && length(grant.objects) > 0 ~}
+  GRANT ${join(", ", grant.privileges)} ON ${grant.object_type} ${join(", ", [for object in grant.objects : "${ident[grant.schema]}.${ident[object]}"])} TO ${ident[user_name]}${grant.with_grant_option ? " WITH GRANT OPTION" : ""};
 %{ endif ~}
-
+EXCEPTION WHEN undefined_table OR undefined_function THEN
+  RAISE WARNING 'Objects granted to % do not exist yet, skipping', ${literal[user_name]};
+END $$;
 %{ endfor ~}
+%{ if length(grants.raw) > 0 ~}
+-- Raw grants, run verbatim
+%{ for statement in grants.raw ~}
+${statement}
+%{ endfor ~}
+%{ endif ~}
+COMMIT;
+\echo 'Custom grants applied for' ${literal[user_name]} 'in' ${literal[db_name]}
 
+%{ endfor ~}
+%{ endfor ~}
 -- ==========================================
--- VERIFY PERMISSIONS
+-- SUMMARY
 -- ==========================================
 
 \c postgres
@@ -87,7 +245,14 @@ SELECT
     r.rolcreaterole as can_create_role,
     r.rolcreatedb as can_create_db,
     r.rolcanlogin as can_login,
-    r.rolreplication as can_replicate
+    r.rolreplication as can_replicate,
+    ARRAY(
+        SELECT g.rolname
+        FROM pg_auth_members m
+        JOIN pg_roles g ON g.oid = m.roleid
+        WHERE m.member = r.oid
+        ORDER BY g.rolname
+    ) as member_of
 FROM pg_roles r
 WHERE r.rolname NOT LIKE 'pg_%'
   AND r.rolname NOT IN ('postgres', 'cloudsqlsuperuser')
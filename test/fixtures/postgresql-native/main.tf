@@ -0,0 +1,75 @@
+# Wires modules/postgresql-native to a provider the way callers do, for TestNativePostgreSQLProvider
+
+variable "host" {
+  type = string
+}
+
+variable "username" {
+  type = string
+}
+
+variable "password" {
+  type      = string
+  sensitive = true
+}
+
+variable "databases" {
+  type = any
+}
+
+variable "groups" {
+  type = any
+}
+
+variable "memberships" {
+  type = any
+}
+
+variable "grants" {
+  type = any
+}
+
+variable "major_version" {
+  type = number
+}
+
+# Login roles the memberships, grants and creators refer to; on Cloud SQL these are google_sql_user resources
+variable "login_roles" {
+  type = list(string)
+}
+
+provider "postgresql" {
+  host      = var.host
+  username  = var.username
+  password  = var.password
+  sslmode   = "disable"
+  superuser = false
+}
+
+resource "postgresql_role" "login_roles" {
+  for_each = toset(var.login_roles)
+
+  name  = each.key
+  login = true
+}
+
+module "postgresql_native" {
+  source = "../../../modules/postgresql-native"
+
+  username      = var.username
+  major_version = var.major_version
+  databases     = var.databases
+  groups        = var.groups
+  memberships   = var.memberships
+  grants        = var.grants
+
+  depends_on = [postgresql_role.login_roles]
+}
+
+output "group_roles" {
+  value = module.postgresql_native.group_roles
+}
+
+output "schemas" {
+  value = module.postgresql_native.schemas
+}
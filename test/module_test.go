@@ -3,6 +3,10 @@ package test
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -60,6 +64,7 @@ func TestModuleStructure(t *testing.T) {
 	t.Log("  - Secret Manager integration")
 	t.Log("  - Performance tuning flags")
 	t.Log("  - Read replica support")
+	t.Log("  - Cascading read replicas")
 	t.Log("  - Backup configuration")
 	t.Log("  - Query insights monitoring")
 }
@@ -308,7 +313,7 @@ func TestBackupConfiguration(t *testing.T) {
 			"instance_name":                   "test-backup",
 			"region":                          "us-central1",
 			"backup_enabled":                  true,
-			"backup_retention_days":           30,
+			"backup_retention_count":          30,
 			"point_in_time_recovery":          true,
 			"transaction_log_retention_days":  7,
 			"default_password_length": 16,
@@ -322,316 +327,1155 @@ func TestBackupConfiguration(t *testing.T) {
 	assert.Contains(t, planOutput, "backup_configuration", "Should configure backups")
 	assert.Contains(t, planOutput, "point_in_time_recovery_enabled", "Should enable PITR")
 
-	t.Log("Backup configuration validated: automated backups with PITR")
-}
-
-// TestReadReplicaConfiguration - Test read replica settings
-func TestReadReplicaConfiguration(t *testing.T) {
-	t.Parallel()
-
-	terraformOptions := &terraform.Options{
+	// Time-based retention keeps one automated backup per day
+	daysOptions := &terraform.Options{
 		TerraformDir: "../",
 		Vars: map[string]interface{}{
-			"project_id":    "test-project",
-			"instance_name": "test-replica",
-			"region":        "us-central1",
-			"read_replicas": map[string]interface{}{
-				"replica1": map[string]interface{}{
-					"region":            "us-east1",
-					"availability_type": "ZONAL",
-					"failover_target":   false,
-				},
-			},
-			"default_password_length": 16,
-			"use_random_suffix": false,
+			"project_id":                     "test-project",
+			"instance_name":                  "test-backup-days",
+			"region":                         "us-central1",
+			"backup_retention_days":          14,
+			"transaction_log_retention_days": 7,
+			"default_password_length":        16,
+			"use_random_suffix":              false,
 		},
+		PlanFilePath: filepath.Join(t.TempDir(), "plan.out"),
 	}
+	plan := terraform.InitAndPlanAndShowWithStruct(t, daysOptions)
+	terraform.RequirePlannedValuesMapKeyExists(t, plan, "google_sql_database_instance.postgres")
+	settings := plannedBlock(t, plan.ResourcePlannedValuesMap["google_sql_database_instance.postgres"].AttributeValues, "settings")
+	retention := plannedBlock(t, plannedBlock(t, settings, "backup_configuration"), "backup_retention_settings")
+	assert.Equal(t, float64(14), retention["retained_backups"], "Should keep one backup per retention day")
+
+	daysOptions.Vars["backup_retention_count"] = 14
+	_, err := terraform.PlanE(t, daysOptions)
+	assert.Error(t, err, "Should reject count- and time-based retention together")
+	assert.Contains(t, err.Error(), "not both")
+
+	delete(daysOptions.Vars, "backup_retention_count")
+	daysOptions.Vars["backup_retention_days"] = 7
+	_, err = terraform.PlanE(t, daysOptions)
+	assert.Error(t, err, "Should reject transaction logs kept as long as the backups")
+	assert.Contains(t, err.Error(), "less than the number of retained backups")
 
-	planOutput := terraform.InitAndPlan(t, terraformOptions)
-
-	// Verify read replica configuration
-	assert.Contains(t, planOutput, "replica1", "Should configure replica1")
-	assert.Contains(t, planOutput, "us-east1", "Should deploy replica in us-east1")
-
-	t.Log("Read replica configuration validated: cross-region replica")
+	t.Log("Backup configuration validated: automated backups with PITR")
 }
 
-// TestPostgreSQLVersionValidation - Test PostgreSQL version constraints
-func TestPostgreSQLVersionValidation(t *testing.T) {
+// TestTransactionLogRetentionEditionLimits - Test PITR retention limits per edition
+func TestTransactionLogRetentionEditionLimits(t *testing.T) {
 	t.Parallel()
 
-	validVersions := []string{
-		"POSTGRES_12",
-		"POSTGRES_13",
-		"POSTGRES_14",
-		"POSTGRES_15",
-		"POSTGRES_16",
-		"POSTGRES_17",
-		"POSTGRES_18",
+	testCases := []struct {
+		name          string
+		preset        string
+		retentionDays int
+		expectError   bool
+	}{
+		{
+			name:          "enterprise_within_limit",
+			preset:        "balanced",
+			retentionDays: 7,
+			expectError:   false,
+		},
+		{
+			name:          "enterprise_over_limit",
+			preset:        "balanced",
+			retentionDays: 14,
+			expectError:   true,
+		},
+		{
+			name:          "enterprise_plus_within_limit",
+			preset:        "performance",
+			retentionDays: 35,
+			expectError:   false,
+		},
 	}
 
-	for _, version := range validVersions {
-		t.Run(version, func(t *testing.T) {
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
 			terraformOptions := &terraform.Options{
 				TerraformDir: "../",
 				Vars: map[string]interface{}{
-					"project_id":        "test-project",
-					"instance_name":     fmt.Sprintf("test-%s", strings.ToLower(version)),
-					"region":            "us-central1",
-					"postgres_version":  version,
-					"default_password_length": 16,
-					"use_random_suffix": false,
+					"project_id":                     "test-project",
+					"instance_name":                  fmt.Sprintf("test-pitr-%s", strings.ReplaceAll(tc.name, "_", "-")),
+					"region":                         "us-central1",
+					"use_preset_config":              tc.preset,
+					"backup_retention_count":         60,
+					"transaction_log_retention_days": tc.retentionDays,
+					"default_password_length":        16,
+					"use_random_suffix":              false,
 				},
 			}
 
-			// Should not fail validation
 			terraform.Init(t, terraformOptions)
-			planOutput := terraform.Plan(t, terraformOptions)
+			_, err := terraform.PlanE(t, terraformOptions)
 
-			assert.Contains(t, planOutput, version, "Should use PostgreSQL version %s", version)
+			if tc.expectError {
+				assert.Error(t, err, "Should reject %d days of transaction logs", tc.retentionDays)
+				assert.Contains(t, err.Error(), "transaction_log_retention_days")
+			} else {
+				assert.NoError(t, err, "Should accept %d days of transaction logs", tc.retentionDays)
+			}
 
-			t.Logf("PostgreSQL version validated: %s", version)
+			t.Logf("Transaction log retention validated: %s", tc.name)
 		})
 	}
 }
 
-// TestInvalidPostgreSQLVersion - Test that invalid versions are rejected
-func TestInvalidPostgreSQLVersion(t *testing.T) {
+// TestBackupDRConfiguration - Test Backup and DR vault, plan and association
+func TestBackupDRConfiguration(t *testing.T) {
 	t.Parallel()
 
 	terraformOptions := &terraform.Options{
 		TerraformDir: "../",
 		Vars: map[string]interface{}{
 			"project_id":        "test-project",
-			"instance_name":     "test-invalid-version",
+			"instance_name":     "test-backup-dr",
 			"region":            "us-central1",
-			"postgres_version":  "POSTGRES_11", // Invalid version
+			"use_preset_config": "balanced",
+			"backup_dr": map[string]interface{}{
+				"enabled":                true,
+				"minimum_retention_days": 30,
+				"retention_days":         90,
+			},
 			"default_password_length": 16,
-			"use_random_suffix": false,
+			"use_random_suffix":       false,
 		},
 	}
 
-	// This should fail during plan due to validation
-	terraform.Init(t, terraformOptions)
-	_, err := terraform.PlanE(t, terraformOptions)
+	planOutput := terraform.InitAndPlan(t, terraformOptions)
 
-	// We expect an error for invalid version
-	assert.Error(t, err, "Should reject invalid PostgreSQL version")
-	assert.Contains(t, err.Error(), "Invalid PostgreSQL version",
-		"Error should mention invalid PostgreSQL version")
+	assert.Contains(t, planOutput, "google_backup_dr_backup_vault.vault", "Should create a backup vault")
+	assert.Contains(t, planOutput, "2592000s", "Should enforce 30 days of minimum retention")
+	assert.Contains(t, planOutput, "google_backup_dr_backup_plan.plan", "Should create a backup plan")
+	assert.Contains(t, planOutput, "sqladmin.googleapis.com/Instance", "Should associate the plan with the instance")
 
-	t.Log("Invalid PostgreSQL version correctly rejected")
+	// Plan retention below the vault's enforced minimum is rejected
+	terraformOptions.Vars["backup_dr"] = map[string]interface{}{
+		"enabled":                true,
+		"minimum_retention_days": 30,
+		"retention_days":         7,
+	}
+	_, err := terraform.PlanE(t, terraformOptions)
+	assert.Error(t, err, "Should reject retention below the enforced minimum")
+	assert.Contains(t, err.Error(), "minimum_retention_days")
+
+	t.Log("Backup and DR validated: vault, plan, association and minimum retention")
 }
 
-// TestNetworkConfiguration - Test network settings
-func TestNetworkConfiguration(t *testing.T) {
+// TestDeletionSafeguards - Test final backup and backup retention defaults on deletion
+func TestDeletionSafeguards(t *testing.T) {
 	t.Parallel()
 
 	terraformOptions := &terraform.Options{
 		TerraformDir: "../",
 		Vars: map[string]interface{}{
 			"project_id":    "test-project",
-			"instance_name": "test-network",
+			"instance_name": "test-deletion",
 			"region":        "us-central1",
-			"ipv4_enabled":  true,
-			"authorized_networks": []interface{}{
-				map[string]interface{}{
-					"name": "office",
-					"cidr": "203.0.113.0/24",
-				},
-				map[string]interface{}{
-					"name": "vpn",
-					"cidr": "198.51.100.0/24",
-				},
+			"environment":   "production",
+			"final_backup": map[string]interface{}{
+				"retention_days": 90,
 			},
-			"ssl_mode":          "ENCRYPTED_ONLY",
 			"default_password_length": 16,
-			"use_random_suffix": false,
+			"use_random_suffix":       false,
 		},
+		PlanFilePath: filepath.Join(t.TempDir(), "plan.out"),
 	}
 
-	planOutput := terraform.InitAndPlan(t, terraformOptions)
+	plan := terraform.InitAndPlanAndShowWithStruct(t, terraformOptions)
 
-	// Verify network configuration
-	assert.Contains(t, planOutput, "authorized_networks", "Should configure authorized networks")
-	assert.Contains(t, planOutput, "203.0.113.0/24", "Should include office network")
-	assert.Contains(t, planOutput, "198.51.100.0/24", "Should include VPN network")
-	assert.Contains(t, planOutput, "ENCRYPTED_ONLY", "Should enforce SSL encryption")
+	// Verify production defaults protect the data on deletion
+	terraform.RequirePlannedValuesMapKeyExists(t, plan, "google_sql_database_instance.postgres")
+	instance := plan.ResourcePlannedValuesMap["google_sql_database_instance.postgres"].AttributeValues
+	settings := plannedBlock(t, instance, "settings")
+	finalBackup := plannedBlock(t, settings, "final_backup_config")
 
-	t.Log("Network configuration validated: authorized networks with SSL enforcement")
+	assert.Equal(t, true, instance["deletion_protection"], "Should enable Terraform deletion protection")
+	assert.Equal(t, true, settings["deletion_protection_enabled"], "Should enable API-level deletion protection")
+	assert.Equal(t, true, settings["retain_backups_on_delete"], "Should retain backups on delete")
+	assert.Equal(t, true, finalBackup["enabled"], "Should take a final backup")
+	assert.Equal(t, float64(90), finalBackup["retention_days"], "Should keep the final backup for 90 days")
+
+	t.Log("Deletion safeguards validated: production defaults with final backup")
 }
 
-// TestQueryInsightsConfiguration - Test monitoring settings
-func TestQueryInsightsConfiguration(t *testing.T) {
+// TestCloneFromValidation - Test that clone mode rejects conflicting restore points
+func TestCloneFromValidation(t *testing.T) {
 	t.Parallel()
 
 	terraformOptions := &terraform.Options{
 		TerraformDir: "../",
 		Vars: map[string]interface{}{
-			"project_id":               "test-project",
-			"instance_name":            "test-insights",
-			"region":                   "us-central1",
-			"query_insights_enabled":   true,
-			"query_string_length":      2048,
-			"record_application_tags":  true,
-			"record_client_address":    true,
-			"query_plans_per_minute":   5,
+			"project_id":    "test-project",
+			"instance_name": "test-clone",
+			"region":        "us-central1",
+			"clone_from": map[string]interface{}{
+				"source_instance_name": "prod-postgres",
+				"point_in_time":        "2024-01-01T00:00:00Z",
+				"backup_run_id":        1234567890,
+			},
 			"default_password_length": 16,
-			"use_random_suffix":        false,
+			"use_random_suffix":       false,
 		},
 	}
 
-	planOutput := terraform.InitAndPlan(t, terraformOptions)
+	terraform.Init(t, terraformOptions)
+	_, err := terraform.PlanE(t, terraformOptions)
 
-	// Verify query insights configuration
-	assert.Contains(t, planOutput, "insights_config", "Should configure query insights")
-	assert.Contains(t, planOutput, "query_insights_enabled", "Should enable query insights")
+	assert.Error(t, err, "Should reject point_in_time combined with backup_run_id")
+	assert.Contains(t, err.Error(), "either point_in_time or backup_run_id",
+		"Error should mention conflicting restore points")
 
-	t.Log("Query Insights configuration validated: full monitoring enabled")
+	t.Log("Conflicting clone restore points correctly rejected")
 }
 
-// TestPerformanceFlagsGeneration - Test auto-generated performance flags
-func TestPerformanceFlagsGeneration(t *testing.T) {
+// TestCloneSkipsExistingDatabases - Test that clone mode only creates databases the source does not have
+func TestCloneSkipsExistingDatabases(t *testing.T) {
 	t.Parallel()
 
 	terraformOptions := &terraform.Options{
 		TerraformDir: "../",
 		Vars: map[string]interface{}{
-			"project_id":                       "test-project",
-			"instance_name":                    "test-perf",
-			"region":                           "us-central1",
-			"use_preset_config":                "performance",
-			"auto_generate_performance_flags":  true,
-			"max_connections":                  "500",
+			"project_id":    "test-project",
+			"instance_name": "test-clone-databases",
+			"region":        "us-central1",
+			"clone_from": map[string]interface{}{
+				"source_instance_name": "prod-postgres",
+				"skip_databases":       []string{"app_db"},
+			},
+			"databases": map[string]interface{}{
+				"app_db":  map[string]interface{}{},
+				"scratch": map[string]interface{}{},
+			},
 			"default_password_length": 16,
-			"use_random_suffix":                false,
+			"use_random_suffix":       false,
 		},
+		PlanFilePath: filepath.Join(t.TempDir(), "plan.out"),
 	}
 
-	planOutput := terraform.InitAndPlan(t, terraformOptions)
+	plan := terraform.InitAndPlanAndShowWithStruct(t, terraformOptions)
 
-	// Verify performance flags are generated
-	assert.Contains(t, planOutput, "shared_buffers", "Should set shared_buffers")
-	assert.Contains(t, planOutput, "effective_cache_size", "Should set effective_cache_size")
-	assert.Contains(t, planOutput, "work_mem", "Should set work_mem")
-	assert.Contains(t, planOutput, "maintenance_work_mem", "Should set maintenance_work_mem")
+	terraform.RequirePlannedValuesMapKeyExists(t, plan, `google_sql_database.databases["scratch"]`)
+	assert.NotContains(t, plan.ResourcePlannedValuesMap, `google_sql_database.databases["app_db"]`, "Should not create a database copied from the source")
+	terraform.RequirePlannedValuesMapKeyExists(t, plan, `google_sql_user.users["app_user"]`)
 
-	t.Log("Performance flags generation validated: PostgreSQL tuning flags configured")
+	t.Log("Clone mode validated: copied databases skipped, new databases and users created")
 }
 
-// TestMaintenanceWindowConfiguration - Test maintenance settings
-func TestMaintenanceWindowConfiguration(t *testing.T) {
+// TestReadReplicaConfiguration - Test read replica settings
+func TestReadReplicaConfiguration(t *testing.T) {
 	t.Parallel()
 
 	terraformOptions := &terraform.Options{
 		TerraformDir: "../",
 		Vars: map[string]interface{}{
-			"project_id":                    "test-project",
-			"instance_name":                 "test-maintenance",
-			"region":                        "us-central1",
-			"maintenance_window_day":        1, // Monday
-			"maintenance_window_hour":       3, // 3 AM
-			"maintenance_window_update_track": "stable",
+			"project_id":    "test-project",
+			"instance_name": "test-replica",
+			"region":        "us-central1",
+			"read_replicas": map[string]interface{}{
+				"replica1": map[string]interface{}{
+					"region":            "us-east1",
+					"availability_type": "ZONAL",
+					"failover_target":   false,
+				},
+			},
 			"default_password_length": 16,
-			"use_random_suffix":             false,
+			"use_random_suffix": false,
 		},
 	}
 
 	planOutput := terraform.InitAndPlan(t, terraformOptions)
 
-	// Verify maintenance window configuration
-	assert.Contains(t, planOutput, "maintenance_window", "Should configure maintenance window")
+	// Verify read replica configuration
+	assert.Contains(t, planOutput, "replica1", "Should configure replica1")
+	assert.Contains(t, planOutput, "us-east1", "Should deploy replica in us-east1")
 
-	t.Log("Maintenance window configuration validated: scheduled maintenance")
+	t.Log("Read replica configuration validated: cross-region replica")
 }
 
-// TestLabelsConfiguration - Test resource labeling
-func TestLabelsConfiguration(t *testing.T) {
+// TestReadReplicaSettingsOverrides - Test per-replica overrides of primary settings
+func TestReadReplicaSettingsOverrides(t *testing.T) {
 	t.Parallel()
 
 	terraformOptions := &terraform.Options{
 		TerraformDir: "../",
 		Vars: map[string]interface{}{
 			"project_id":    "test-project",
-			"instance_name": "test-labels",
+			"instance_name": "test-replica-overrides",
 			"region":        "us-central1",
-			"labels": map[string]interface{}{
-				"environment": "production",
-				"team":        "platform",
-				"managed_by":  "terraform",
+			"environment":   "production",
+			"authorized_networks": []interface{}{
+				map[string]interface{}{
+					"name": "office",
+					"cidr": "203.0.113.0/24",
+				},
+			},
+			"read_replicas": map[string]interface{}{
+				"dr": map[string]interface{}{
+					"region":              "europe-west1",
+					"deletion_protection": true,
+					"backup_location":     "eu",
+					"labels":              map[string]interface{}{"environment": "dr"},
+					"authorized_networks": []interface{}{
+						map[string]interface{}{
+							"name": "eu-office",
+							"cidr": "198.51.100.0/24",
+						},
+					},
+					"maintenance_window": map[string]interface{}{
+						"day":  3,
+						"hour": 22,
+					},
+				},
 			},
 			"default_password_length": 16,
-			"use_random_suffix": false,
+			"use_random_suffix":       false,
 		},
+		PlanFilePath: filepath.Join(t.TempDir(), "plan.out"),
 	}
 
-	planOutput := terraform.InitAndPlan(t, terraformOptions)
+	plan := terraform.InitAndPlanAndShowWithStruct(t, terraformOptions)
 
-	// Verify labels are configured
-	assert.Contains(t, planOutput, "environment", "Should include environment label")
-	assert.Contains(t, planOutput, "platform", "Should include team label")
-	assert.Contains(t, planOutput, "terraform", "Should include managed_by label")
+	// Verify overrides and inherited settings on the replica itself
+	address := `google_sql_database_instance.read_replicas["dr"]`
+	terraform.RequirePlannedValuesMapKeyExists(t, plan, address)
+	replica := plan.ResourcePlannedValuesMap[address].AttributeValues
+	settings := plannedBlock(t, replica, "settings")
 
-	t.Log("Labels configuration validated: resource tagging configured")
+	assert.Equal(t, "europe-west1", replica["region"], "Should deploy replica in europe-west1")
+	assert.Equal(t, true, replica["deletion_protection"], "Should override deletion protection")
+	assert.Equal(t, "eu", plannedBlock(t, settings, "backup_configuration")["location"], "Should override the backup location")
+
+	networks := []interface{}{}
+	for _, network := range plannedBlock(t, settings, "ip_configuration")["authorized_networks"].([]interface{}) {
+		networks = append(networks, network.(map[string]interface{})["value"])
+	}
+	assert.Equal(t, []interface{}{"198.51.100.0/24"}, networks, "Should replace the primary's authorized networks")
+
+	maintenance := plannedBlock(t, settings, "maintenance_window")
+	assert.Equal(t, float64(3), maintenance["day"], "Should override the maintenance day")
+	assert.Equal(t, float64(22), maintenance["hour"], "Should override the maintenance hour")
+	assert.Equal(t, "stable", maintenance["update_track"], "Should inherit the maintenance update track")
+
+	assert.Equal(t, true, plannedBlock(t, settings, "insights_config")["query_insights_enabled"], "Should inherit query insights settings")
+
+	labels := settings["user_labels"].(map[string]interface{})
+	assert.Equal(t, "dr", labels["environment"], "Should override the environment label")
+	assert.Equal(t, "read-replica", labels["type"], "Should keep the inherited labels")
+
+	t.Log("Read replica overrides validated: per-replica networks, maintenance, backup location and labels")
 }
 
-// TestDiskAutoresizeConfiguration - Test disk autoresize settings
-func TestDiskAutoresizeConfiguration(t *testing.T) {
+// TestCascadingReplicaConfiguration - Test replicas that replicate from another replica
+func TestCascadingReplicaConfiguration(t *testing.T) {
 	t.Parallel()
 
 	terraformOptions := &terraform.Options{
 		TerraformDir: "../",
 		Vars: map[string]interface{}{
-			"project_id":                "test-project",
-			"instance_name":             "test-autoresize",
-			"region":                    "us-central1",
-			"disk_autoresize":           true,
-			"disk_autoresize_limit_gb":  1000,
+			"project_id":    "test-project",
+			"instance_name": "test-cascade",
+			"region":        "us-central1",
+			"read_replicas": map[string]interface{}{
+				"eu": map[string]interface{}{
+					"region": "europe-west1",
+				},
+				"eu-local": map[string]interface{}{
+					"region": "europe-west1",
+					"source": "eu",
+				},
+			},
 			"default_password_length": 16,
-			"use_random_suffix":         false,
+			"use_random_suffix":       false,
 		},
 	}
 
 	planOutput := terraform.InitAndPlan(t, terraformOptions)
 
-	// Verify disk autoresize configuration
-	assert.Contains(t, planOutput, "disk_autoresize", "Should configure disk autoresize")
+	// Verify the cascading replica is planned in its own level
+	assert.Contains(t, planOutput, "read_replicas_level2", "Should plan cascading replica as level 2")
+	assert.Contains(t, planOutput, "test-cascade-eu-local", "Should configure eu-local replica")
 
-	t.Log("Disk autoresize configuration validated: automatic storage expansion enabled")
+	t.Log("Cascading replica configuration validated: regional replica feeding a local replica")
 }
 
-// TestOutputsStructure - Test that all expected outputs are defined
-func TestOutputsStructure(t *testing.T) {
+// TestCascadingReplicaCycleRejected - Test that cyclic replica sources are rejected
+func TestCascadingReplicaCycleRejected(t *testing.T) {
 	t.Parallel()
 
 	terraformOptions := &terraform.Options{
 		TerraformDir: "../",
 		Vars: map[string]interface{}{
-			"project_id":        "test-project",
-			"instance_name":     "test-outputs",
-			"region":            "us-central1",
+			"project_id":    "test-project",
+			"instance_name": "test-cascade-cycle",
+			"region":        "us-central1",
+			"read_replicas": map[string]interface{}{
+				"a": map[string]interface{}{
+					"source": "b",
+				},
+				"b": map[string]interface{}{
+					"source": "a",
+				},
+			},
 			"default_password_length": 16,
-			"use_random_suffix": false,
+			"use_random_suffix":       false,
 		},
 	}
 
 	terraform.Init(t, terraformOptions)
+	_, err := terraform.PlanE(t, terraformOptions)
 
-	// Get output list from plan
-	outputs, err := terraform.OutputListE(t, terraformOptions, "database_names")
+	assert.Error(t, err, "Should reject cyclic replica sources")
+	assert.Contains(t, err.Error(), "must not form a cycle", "Error should mention the cycle")
 
-	// We expect an error since we haven't applied, but we can verify the output exists
-	if err != nil {
-		// This is expected - we're just checking the output is defined
-		t.Log("Output 'database_names' is defined (expected error without apply)")
-	} else {
-		t.Log("Outputs are accessible:", outputs)
+	t.Log("Cyclic replica sources correctly rejected")
+}
+
+// TestDisasterRecoveryReplicaConfiguration - Test Enterprise Plus DR replica and switchover
+func TestDisasterRecoveryReplicaConfiguration(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name            string
+		switchover      bool
+		expectedPrimary string
+	}{
+		{
+			name:            "primary",
+			switchover:      false,
+			expectedPrimary: "test-dr",
+		},
+		{
+			name:            "switchover",
+			switchover:      true,
+			expectedPrimary: "test-dr-dr",
+		},
 	}
 
-	expectedOutputs := []string{
-		"instance_name",
-		"instance_connection_name",
-		"public_ip_address",
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			terraformOptions := &terraform.Options{
+				TerraformDir: "../",
+				Vars: map[string]interface{}{
+					"project_id":        "test-project",
+					"instance_name":     "test-dr",
+					"region":            "us-central1",
+					"use_preset_config": "performance",
+					"dr_switchover":     tc.switchover,
+					"read_replicas": map[string]interface{}{
+						"dr": map[string]interface{}{
+							"region":            "us-east1",
+							"disaster_recovery": true,
+						},
+						"reads": map[string]interface{}{},
+					},
+					"read_pools": map[string]interface{}{
+						"reporting": map[string]interface{}{"node_count": 2},
+					},
+					"default_password_length": 16,
+					"use_random_suffix":       false,
+				},
+				PlanFilePath: filepath.Join(t.TempDir(), "plan.out"),
+			}
+
+			plan := terraform.InitAndPlanAndShowWithStruct(t, terraformOptions)
+			planOutput := terraform.Show(t, terraformOptions)
+
+			// Verify the replication cluster points at the DR replica and reports the primary role
+			assert.Contains(t, planOutput, "replication_cluster", "Should configure replication cluster")
+			assert.Contains(t, planOutput, "failover_dr_replica_name", "Should set the DR replica on the primary")
+			assert.Contains(t, planOutput, tc.expectedPrimary, "Should report %s as primary", tc.expectedPrimary)
+
+			// Users and databases live on the instance holding the primary role
+			for _, address := range []string{`google_sql_user.users["app_user"]`, `google_sql_database.databases["main"]`} {
+				terraform.RequirePlannedValuesMapKeyExists(t, plan, address)
+				assert.Equal(t, tc.expectedPrimary, plan.ResourcePlannedValuesMap[address].AttributeValues["instance"], "Should create %s on the primary", address)
+			}
+
+			// New replicas and read pools start from the primary; a switchover does not replace existing ones,
+			// as they ignore changes to master_instance_name
+			for _, address := range []string{`google_sql_database_instance.read_replicas["reads"]`, `google_sql_database_instance.read_pools["reporting"]`} {
+				terraform.RequirePlannedValuesMapKeyExists(t, plan, address)
+				assert.Equal(t, tc.expectedPrimary, plan.ResourcePlannedValuesMap[address].AttributeValues["master_instance_name"], "Should replicate %s from the primary", address)
+			}
+			dr := plan.ResourcePlannedValuesMap[`google_sql_database_instance.read_replicas["dr"]`].AttributeValues
+			if tc.switchover {
+				assert.Equal(t, "CLOUD_SQL_INSTANCE", dr["instance_type"], "Should promote the DR replica")
+				assert.ElementsMatch(t, []interface{}{"test-dr", "test-dr-reads"}, dr["replica_names"], "Should make the DR replica the source of the other instances")
+			} else {
+				assert.Equal(t, "READ_REPLICA_INSTANCE", dr["instance_type"], "Should keep the DR replica a replica")
+			}
+
+			t.Logf("Disaster recovery replica validated: %s holds the primary role", tc.expectedPrimary)
+		})
+	}
+}
+
+// TestDisasterRecoveryRequiresEnterprisePlus - Test that DR replicas are rejected on ENTERPRISE
+func TestDisasterRecoveryRequiresEnterprisePlus(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":        "test-project",
+			"instance_name":     "test-dr-enterprise",
+			"region":            "us-central1",
+			"use_preset_config": "balanced",
+			"read_replicas": map[string]interface{}{
+				"dr": map[string]interface{}{
+					"region":            "us-east1",
+					"disaster_recovery": true,
+				},
+			},
+			"default_password_length": 16,
+			"use_random_suffix":       false,
+		},
+	}
+
+	terraform.Init(t, terraformOptions)
+	_, err := terraform.PlanE(t, terraformOptions)
+
+	assert.Error(t, err, "Should reject DR replica on ENTERPRISE edition")
+	assert.Contains(t, err.Error(), "ENTERPRISE_PLUS", "Error should mention ENTERPRISE_PLUS")
+
+	t.Log("DR replica on ENTERPRISE edition correctly rejected")
+}
+
+// TestDisasterRecoverySwitchoverApply - Switch over to the DR replica and back in a real project, following the
+// state steps in the README. Creates Enterprise Plus instances, so it only runs when GOOGLE_DR_TEST_PROJECT is set
+func TestDisasterRecoverySwitchoverApply(t *testing.T) {
+	projectID := os.Getenv("GOOGLE_DR_TEST_PROJECT")
+	if projectID == "" {
+		t.Skip("GOOGLE_DR_TEST_PROJECT is not set; skipping the switchover apply test")
+	}
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":        projectID,
+			"instance_name":     "test-dr-apply",
+			"region":            "us-central1",
+			"use_preset_config": "performance",
+			"dr_switchover":     false,
+			"read_replicas": map[string]interface{}{
+				"dr": map[string]interface{}{
+					"region":            "us-east1",
+					"disaster_recovery": true,
+				},
+			},
+			"read_pools": map[string]interface{}{
+				"reporting": map[string]interface{}{"node_count": 1},
+			},
+			"databases": map[string]interface{}{
+				"app_db": map[string]interface{}{},
+			},
+			"users": map[string]interface{}{
+				"app_user": map[string]interface{}{"role": "readwrite"},
+			},
+			"deletion_protection":         false,
+			"deletion_protection_enabled": false,
+			"default_password_length":     16,
+		},
+	}
+
+	defer terraform.Destroy(t, terraformOptions)
+	terraform.InitAndApply(t, terraformOptions)
+
+	for _, switchover := range []bool{true, false} {
+		terraformOptions.Vars["dr_switchover"] = switchover
+
+		// The switchover must keep the databases, users and read pools
+		planOptions, err := terraformOptions.Clone()
+		require.NoError(t, err)
+		planOptions.PlanFilePath = filepath.Join(t.TempDir(), "plan.out")
+		plan := terraform.InitAndPlanAndShowWithStruct(t, planOptions)
+		for _, address := range []string{`google_sql_database.databases["app_db"]`, `google_sql_user.users["app_user"]`, `google_sql_database_instance.read_pools["reporting"]`} {
+			if change, ok := plan.ResourceChangesMap[address]; ok {
+				assert.False(t, change.Change.Actions.Replace() || change.Change.Actions.Delete(), "Should not replace %s on switchover", address)
+			}
+		}
+
+		terraform.Apply(t, terraformOptions)
+		cluster := parseOutputJSON(t, terraform.OutputJson(t, terraformOptions, "replication_cluster"))
+		primary := cluster["primary_instance_name"].(string)
+		assert.Equal(t, switchover, cluster["switchover_active"], "Should report the switchover state")
+
+		repointSwitchoverState(t, terraformOptions, projectID, primary)
+		terraform.Apply(t, terraformOptions)
+
+		exitCode := terraform.PlanExitCode(t, terraformOptions)
+		assert.Equal(t, 0, exitCode, "Should have no changes after re-pointing to %s", primary)
+	}
+
+	t.Log("Switchover to the DR replica and back validated")
+}
+
+// repointSwitchoverState moves the databases and users in the state to the instance holding the primary role,
+// as the README describes for the step after a switchover
+func repointSwitchoverState(t *testing.T, terraformOptions *terraform.Options, projectID, primary string) {
+	imports := map[string]string{}
+	for name := range terraformOptions.Vars["databases"].(map[string]interface{}) {
+		imports[fmt.Sprintf("google_sql_database.databases[%q]", name)] = fmt.Sprintf("projects/%s/instances/%s/databases/%s", projectID, primary, name)
+	}
+	for name := range terraformOptions.Vars["users"].(map[string]interface{}) {
+		imports[fmt.Sprintf("google_sql_user.users[%q]", name)] = fmt.Sprintf("%s/%s/%s", projectID, primary, name)
+	}
+
+	for address, id := range imports {
+		terraform.RunTerraformCommand(t, terraformOptions, "state", "rm", address)
+		args := append([]string{"import", "-input=false"}, terraform.FormatTerraformVarsAsArgs(terraformOptions.Vars)...)
+		terraform.RunTerraformCommand(t, terraformOptions, append(args, address, id)...)
+	}
+}
+
+// TestReadPoolConfiguration - Test Enterprise Plus read pools
+func TestReadPoolConfiguration(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":        "test-project",
+			"instance_name":     "test-read-pool",
+			"region":            "us-central1",
+			"use_preset_config": "performance",
+			"read_pools": map[string]interface{}{
+				"reporting": map[string]interface{}{
+					"node_count": 2,
+				},
+				"analytics": map[string]interface{}{
+					"autoscaling": map[string]interface{}{
+						"min_node_count": 1,
+						"max_node_count": 4,
+					},
+				},
+			},
+			"default_password_length": 16,
+			"use_random_suffix":       false,
+		},
+	}
+
+	planOutput := terraform.InitAndPlan(t, terraformOptions)
+
+	// Verify read pools are planned with fixed and autoscaled node counts
+	assert.Contains(t, planOutput, "READ_POOL_INSTANCE", "Should create read pool instances")
+	assert.Contains(t, planOutput, "test-read-pool-reporting", "Should configure reporting pool")
+	assert.Contains(t, planOutput, "read_pool_auto_scale_config", "Should configure autoscaling for analytics pool")
+
+	t.Log("Read pool configuration validated: fixed and autoscaled pools")
+}
+
+// TestReadPoolRequiresEnterprisePlus - Test that read pools are rejected on ENTERPRISE
+func TestReadPoolRequiresEnterprisePlus(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":        "test-project",
+			"instance_name":     "test-read-pool-enterprise",
+			"region":            "us-central1",
+			"use_preset_config": "budget",
+			"read_pools": map[string]interface{}{
+				"reporting": map[string]interface{}{
+					"node_count": 2,
+				},
+			},
+			"default_password_length": 16,
+			"use_random_suffix":       false,
+		},
+	}
+
+	terraform.Init(t, terraformOptions)
+	_, err := terraform.PlanE(t, terraformOptions)
+
+	assert.Error(t, err, "Should reject read pools on ENTERPRISE edition")
+	assert.Contains(t, err.Error(), "Read pools require the ENTERPRISE_PLUS edition",
+		"Error should mention the edition requirement")
+
+	t.Log("Read pool on ENTERPRISE edition correctly rejected")
+}
+
+// TestConnectionPoolingConfiguration - Test managed pooling and PgBouncer fallback
+func TestConnectionPoolingConfiguration(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		preset         string
+		expectedInPlan string
+	}{
+		{
+			name:           "managed",
+			preset:         "performance",
+			expectedInPlan: "connection_pool_config",
+		},
+		{
+			name:           "pgbouncer",
+			preset:         "budget",
+			expectedInPlan: "pgbouncer.ini",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			terraformOptions := &terraform.Options{
+				TerraformDir: "../",
+				Vars: map[string]interface{}{
+					"project_id":        "test-project",
+					"instance_name":     fmt.Sprintf("test-pooling-%s", tc.name),
+					"region":            "us-central1",
+					"use_preset_config": tc.preset,
+					"connection_pooling": map[string]interface{}{
+						"enabled":             true,
+						"pool_mode":           "transaction",
+						"pool_size":           25,
+						"client_idle_timeout": 300,
+					},
+					"default_password_length": 16,
+					"use_random_suffix":       false,
+				},
+			}
+
+			planOutput := terraform.InitAndPlan(t, terraformOptions)
+
+			assert.Contains(t, planOutput, tc.expectedInPlan, "Should configure %s pooling", tc.name)
+
+			t.Logf("Connection pooling validated: %s", tc.name)
+		})
+	}
+}
+
+// TestScheduledExportsConfiguration - Test export bucket and per-database Cloud Scheduler jobs
+func TestScheduledExportsConfiguration(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":        "test-project",
+			"instance_name":     "test-exports",
+			"region":            "us-central1",
+			"use_preset_config": "performance",
+			"databases": map[string]interface{}{
+				"app_db":    map[string]interface{}{},
+				"analytics": map[string]interface{}{},
+				"billing":   map[string]interface{}{},
+			},
+			"read_replicas": map[string]interface{}{
+				"dr": map[string]interface{}{
+					"region":            "us-east1",
+					"disaster_recovery": true,
+				},
+			},
+			"exports": map[string]interface{}{
+				"enabled":             true,
+				"schedule":            "0 2 * * *",
+				"schedule_overrides":  map[string]interface{}{"analytics": "0 4 * * *"},
+				"retention_days":      90,
+				"coldline_after_days": 30,
+			},
+			"default_password_length": 16,
+			"use_random_suffix":       false,
+		},
+		PlanFilePath: filepath.Join(t.TempDir(), "plan.out"),
+	}
+
+	plan := terraform.InitAndPlanAndShowWithStruct(t, terraformOptions)
+	planOutput := terraform.Show(t, terraformOptions)
+
+	assert.Contains(t, planOutput, "test-exports-exports", "Should create the export bucket")
+	assert.Contains(t, planOutput, "test-exports-export-app_db", "Should schedule an export for app_db")
+	assert.Contains(t, planOutput, "test-exports-export-analytics", "Should schedule an export for analytics")
+	assert.Contains(t, planOutput, "0 4 * * *", "Should apply the per-database schedule override")
+
+	// Cloud SQL runs one export at a time, so the other databases export 30 minutes apart
+	for db, schedule := range map[string]string{"analytics": "0 4 * * *", "app_db": "0 2 * * *", "billing": "30 2 * * *"} {
+		address := fmt.Sprintf("google_cloud_scheduler_job.exports[%q]", db)
+		terraform.RequirePlannedValuesMapKeyExists(t, plan, address)
+		assert.Equal(t, schedule, plan.ResourcePlannedValuesMap[address].AttributeValues["schedule"], "Should schedule the %s export at %s", db, schedule)
+	}
+	assert.Contains(t, planOutput, "COLDLINE", "Should move older exports to COLDLINE")
+
+	// Both the primary and the DR replica may run exports, so both service accounts need write access
+	for _, key := range []string{"primary", "dr"} {
+		address := fmt.Sprintf("google_storage_bucket_iam_member.exports_writer[%q]", key)
+		terraform.RequirePlannedValuesMapKeyExists(t, plan, address)
+		assert.Equal(t, "roles/storage.objectAdmin", plan.ResourcePlannedValuesMap[address].AttributeValues["role"], "Should grant the %s service account write access", key)
+	}
+
+	// The scheduler can only export, and only from this module's primary and DR replica
+	terraform.RequirePlannedValuesMapKeyExists(t, plan, "google_project_iam_custom_role.exports_scheduler[0]")
+	role := plan.ResourcePlannedValuesMap["google_project_iam_custom_role.exports_scheduler[0]"].AttributeValues
+	assert.Equal(t, []interface{}{"cloudsql.instances.export"}, role["permissions"], "Should only grant the export permission")
+
+	terraform.RequirePlannedValuesMapKeyExists(t, plan, "google_project_iam_member.exports_scheduler[0]")
+	binding := plan.ResourcePlannedValuesMap["google_project_iam_member.exports_scheduler[0]"].AttributeValues
+	condition := plannedBlock(t, binding, "condition")
+	assert.Equal(t,
+		`resource.name == "projects/test-project/instances/test-exports" || resource.name == "projects/test-project/instances/test-exports-dr"`,
+		condition["expression"],
+		"Should scope the export role to the primary and DR replica",
+	)
+
+	t.Log("Scheduled exports validated: bucket, IAM for the primary and DR replica, scoped scheduler role and staggered scheduler jobs")
+}
+
+// TestMigrationConfiguration - Test Database Migration Service profiles, job and logical decoding flags
+func TestMigrationConfiguration(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":         "test-project",
+			"instance_name":      "test-migration",
+			"region":             "us-central1",
+			"use_preset_config":  "balanced",
+			"private_network_id": "projects/test-project/global/networks/default",
+			"migration": map[string]interface{}{
+				"enabled": true,
+				"source": map[string]interface{}{
+					"host":     "10.0.0.5",
+					"username": "replicator",
+				},
+			},
+			"databases": map[string]interface{}{
+				"app_db": map[string]interface{}{},
+			},
+			"users": map[string]interface{}{
+				"app_user": map[string]interface{}{"role": "readwrite"},
+			},
+			"migration_source_password": "test-password",
+			"default_password_length":   16,
+			"use_random_suffix":         false,
+		},
+	}
+
+	planOutput := terraform.InitAndPlan(t, terraformOptions)
+
+	assert.Contains(t, planOutput, "test-migration-source", "Should create the source connection profile")
+	assert.Contains(t, planOutput, "test-migration-destination", "Should create the destination connection profile")
+	assert.Contains(t, planOutput, "CONTINUOUS", "Should create a continuous migration job")
+	assert.Contains(t, planOutput, "cloudsql.logical_decoding", "Should enable logical decoding")
+	assert.Contains(t, planOutput, "cloudsql.enable_pglogical", "Should enable pglogical")
+	assert.NotContains(t, planOutput, "google_sql_database.databases", "Should leave the destination databases to the migration job")
+	assert.NotContains(t, planOutput, "google_sql_user.users", "Should not create users before the instance is promoted")
+	assert.NotContains(t, planOutput, "terraform_data.migration_promote", "Should not promote before the toggle is set")
+	assert.Contains(t, planOutput, "terraform_data.migration_destination", "Should record the instance as a migration destination")
+
+	// The promote toggle finishes the cutover and creates the users
+	terraformOptions.Vars["migration"].(map[string]interface{})["promote"] = true
+	planOutput = terraform.Plan(t, terraformOptions)
+	assert.Contains(t, planOutput, "terraform_data.migration_promote[0]", "Should promote the instance")
+	assert.Contains(t, planOutput, `google_sql_user.users["app_user"]`, "Should create users after the promote")
+	assert.NotContains(t, planOutput, "google_sql_database.databases", "Should not recreate the migrated databases")
+
+	// A migration without a source is rejected
+	terraformOptions.Vars["migration"] = map[string]interface{}{"enabled": true}
+	_, err := terraform.PlanE(t, terraformOptions)
+	assert.Error(t, err, "Should require a migration source")
+	assert.Contains(t, err.Error(), "migration.source is required")
+
+	t.Log("Migration validated: connection profiles, continuous job, logical decoding flags and empty destination until promoted")
+}
+
+// TestPostgreSQLVersionValidation - Test PostgreSQL version constraints
+func TestPostgreSQLVersionValidation(t *testing.T) {
+	t.Parallel()
+
+	validVersions := []string{
+		"POSTGRES_12",
+		"POSTGRES_13",
+		"POSTGRES_14",
+		"POSTGRES_15",
+		"POSTGRES_16",
+		"POSTGRES_17",
+		"POSTGRES_18",
+	}
+
+	for _, version := range validVersions {
+		t.Run(version, func(t *testing.T) {
+			terraformOptions := &terraform.Options{
+				TerraformDir: "../",
+				Vars: map[string]interface{}{
+					"project_id":        "test-project",
+					"instance_name":     fmt.Sprintf("test-%s", strings.ToLower(version)),
+					"region":            "us-central1",
+					"postgres_version":  version,
+					"users": map[string]interface{}{
+						"admin_user": map[string]interface{}{"role": "admin"},
+					},
+					"generate_permission_script": true,
+					"default_password_length": 16,
+					"use_random_suffix": false,
+				},
+			}
+
+			// Should not fail validation
+			terraform.Init(t, terraformOptions)
+			planOutput := terraform.Plan(t, terraformOptions)
+
+			assert.Contains(t, planOutput, version, "Should use PostgreSQL version %s", version)
+
+			// The permission script only uses statements the major version supports
+			majorVersion, err := strconv.Atoi(strings.TrimPrefix(version, "POSTGRES_"))
+			require.NoError(t, err)
+			if majorVersion >= 14 {
+				assert.Contains(t, planOutput, "GRANT pg_read_all_data TO \"admin_user\"", "Should grant pg_read_all_data on %s", version)
+			} else {
+				assert.NotContains(t, planOutput, "GRANT pg_read_all_data", "pg_read_all_data does not exist on %s", version)
+			}
+			if majorVersion < 15 {
+				assert.Contains(t, planOutput, "REVOKE CREATE ON SCHEMA \"public\" FROM PUBLIC", "Should revoke the default CREATE on public on %s", version)
+			} else {
+				assert.NotContains(t, planOutput, "REVOKE CREATE ON SCHEMA \"public\" FROM PUBLIC", "public has no default CREATE on %s", version)
+			}
+
+			t.Logf("PostgreSQL version validated: %s", version)
+		})
+	}
+}
+
+// TestInvalidPostgreSQLVersion - Test that invalid versions are rejected
+func TestInvalidPostgreSQLVersion(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":        "test-project",
+			"instance_name":     "test-invalid-version",
+			"region":            "us-central1",
+			"postgres_version":  "POSTGRES_11", // Invalid version
+			"default_password_length": 16,
+			"use_random_suffix": false,
+		},
+	}
+
+	// This should fail during plan due to validation
+	terraform.Init(t, terraformOptions)
+	_, err := terraform.PlanE(t, terraformOptions)
+
+	// We expect an error for invalid version
+	assert.Error(t, err, "Should reject invalid PostgreSQL version")
+	assert.Contains(t, err.Error(), "Invalid PostgreSQL version",
+		"Error should mention invalid PostgreSQL version")
+
+	t.Log("Invalid PostgreSQL version correctly rejected")
+}
+
+// TestNetworkConfiguration - Test network settings
+func TestNetworkConfiguration(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":    "test-project",
+			"instance_name": "test-network",
+			"region":        "us-central1",
+			"ipv4_enabled":  true,
+			"authorized_networks": []interface{}{
+				map[string]interface{}{
+					"name": "office",
+					"cidr": "203.0.113.0/24",
+				},
+				map[string]interface{}{
+					"name": "vpn",
+					"cidr": "198.51.100.0/24",
+				},
+			},
+			"ssl_mode":          "ENCRYPTED_ONLY",
+			"default_password_length": 16,
+			"use_random_suffix": false,
+		},
+	}
+
+	planOutput := terraform.InitAndPlan(t, terraformOptions)
+
+	// Verify network configuration
+	assert.Contains(t, planOutput, "authorized_networks", "Should configure authorized networks")
+	assert.Contains(t, planOutput, "203.0.113.0/24", "Should include office network")
+	assert.Contains(t, planOutput, "198.51.100.0/24", "Should include VPN network")
+	assert.Contains(t, planOutput, "ENCRYPTED_ONLY", "Should enforce SSL encryption")
+
+	t.Log("Network configuration validated: authorized networks with SSL enforcement")
+}
+
+// TestQueryInsightsConfiguration - Test monitoring settings
+func TestQueryInsightsConfiguration(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":               "test-project",
+			"instance_name":            "test-insights",
+			"region":                   "us-central1",
+			"query_insights_enabled":   true,
+			"query_string_length":      2048,
+			"record_application_tags":  true,
+			"record_client_address":    true,
+			"query_plans_per_minute":   5,
+			"default_password_length": 16,
+			"use_random_suffix":        false,
+		},
+	}
+
+	planOutput := terraform.InitAndPlan(t, terraformOptions)
+
+	// Verify query insights configuration
+	assert.Contains(t, planOutput, "insights_config", "Should configure query insights")
+	assert.Contains(t, planOutput, "query_insights_enabled", "Should enable query insights")
+
+	t.Log("Query Insights configuration validated: full monitoring enabled")
+}
+
+// TestPerformanceFlagsGeneration - Test auto-generated performance flags
+func TestPerformanceFlagsGeneration(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":                       "test-project",
+			"instance_name":                    "test-perf",
+			"region":                           "us-central1",
+			"use_preset_config":                "performance",
+			"auto_generate_performance_flags":  true,
+			"max_connections":                  "500",
+			"default_password_length": 16,
+			"use_random_suffix":                false,
+		},
+	}
+
+	planOutput := terraform.InitAndPlan(t, terraformOptions)
+
+	// Verify performance flags are generated
+	assert.Contains(t, planOutput, "shared_buffers", "Should set shared_buffers")
+	assert.Contains(t, planOutput, "effective_cache_size", "Should set effective_cache_size")
+	assert.Contains(t, planOutput, "work_mem", "Should set work_mem")
+	assert.Contains(t, planOutput, "maintenance_work_mem", "Should set maintenance_work_mem")
+
+	t.Log("Performance flags generation validated: PostgreSQL tuning flags configured")
+}
+
+// TestMaintenanceWindowConfiguration - Test maintenance settings
+func TestMaintenanceWindowConfiguration(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":                    "test-project",
+			"instance_name":                 "test-maintenance",
+			"region":                        "us-central1",
+			"maintenance_window_day":        1, // Monday
+			"maintenance_window_hour":       3, // 3 AM
+			"maintenance_window_update_track": "stable",
+			"default_password_length": 16,
+			"use_random_suffix":             false,
+		},
+	}
+
+	planOutput := terraform.InitAndPlan(t, terraformOptions)
+
+	// Verify maintenance window configuration
+	assert.Contains(t, planOutput, "maintenance_window", "Should configure maintenance window")
+
+	t.Log("Maintenance window configuration validated: scheduled maintenance")
+}
+
+// TestLabelsConfiguration - Test resource labeling
+func TestLabelsConfiguration(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":    "test-project",
+			"instance_name": "test-labels",
+			"region":        "us-central1",
+			"labels": map[string]interface{}{
+				"environment": "production",
+				"team":        "platform",
+				"managed_by":  "terraform",
+			},
+			"default_password_length": 16,
+			"use_random_suffix": false,
+		},
+	}
+
+	planOutput := terraform.InitAndPlan(t, terraformOptions)
+
+	// Verify labels are configured
+	assert.Contains(t, planOutput, "environment", "Should include environment label")
+	assert.Contains(t, planOutput, "platform", "Should include team label")
+	assert.Contains(t, planOutput, "terraform", "Should include managed_by label")
+
+	t.Log("Labels configuration validated: resource tagging configured")
+}
+
+// TestDiskAutoresizeConfiguration - Test disk autoresize settings
+func TestDiskAutoresizeConfiguration(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":                "test-project",
+			"instance_name":             "test-autoresize",
+			"region":                    "us-central1",
+			"disk_autoresize":           true,
+			"disk_autoresize_limit_gb":  1000,
+			"default_password_length": 16,
+			"use_random_suffix":         false,
+		},
+	}
+
+	planOutput := terraform.InitAndPlan(t, terraformOptions)
+
+	// Verify disk autoresize configuration
+	assert.Contains(t, planOutput, "disk_autoresize", "Should configure disk autoresize")
+
+	t.Log("Disk autoresize configuration validated: automatic storage expansion enabled")
+}
+
+// TestOutputsStructure - Test that all expected outputs are defined
+func TestOutputsStructure(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":        "test-project",
+			"instance_name":     "test-outputs",
+			"region":            "us-central1",
+			"default_password_length": 16,
+			"use_random_suffix": false,
+		},
+	}
+
+	terraform.Init(t, terraformOptions)
+
+	// Get output list from plan
+	outputs, err := terraform.OutputListE(t, terraformOptions, "database_names")
+
+	// We expect an error since we haven't applied, but we can verify the output exists
+	if err != nil {
+		// This is expected - we're just checking the output is defined
+		t.Log("Output 'database_names' is defined (expected error without apply)")
+	} else {
+		t.Log("Outputs are accessible:", outputs)
+	}
+
+	expectedOutputs := []string{
+		"instance_name",
+		"instance_connection_name",
+		"public_ip_address",
 		"private_ip_address",
 		"databases",
 		"database_names",
@@ -639,76 +1483,990 @@ func TestOutputsStructure(t *testing.T) {
 		"user_secret_ids",
 		"cloud_sql_proxy_command",
 		"connection_strings",
+		"connection_pooling",
 		"read_replicas",
+		"replication_cluster",
+		"read_pools",
+		"exports",
+		"migration",
+		"write_endpoint",
 		"configuration",
 		"metrics_dashboard_url",
 		"postgres_info",
 	}
 
-	t.Log("Expected outputs:")
-	for _, output := range expectedOutputs {
-		t.Logf("  - %s", output)
+	t.Log("Expected outputs:")
+	for _, output := range expectedOutputs {
+		t.Logf("  - %s", output)
+	}
+}
+
+// TestCustomPresetConfiguration - Test custom preset with specific values
+func TestCustomPresetConfiguration(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":         "test-project",
+			"instance_name":      "test-custom",
+			"region":             "us-central1",
+			"use_preset_config":  "custom",
+			"machine_type":       "db-custom-16-65536", // 16 vCPUs, 64GB RAM
+			"disk_size_gb":       2000,
+			"sql_edition":        "ENTERPRISE_PLUS",
+			"default_password_length": 16,
+			"use_random_suffix":  false,
+		},
+	}
+
+	planOutput := terraform.InitAndPlan(t, terraformOptions)
+
+	// Verify custom configuration
+	assert.Contains(t, planOutput, "db-custom-16-65536", "Should use custom machine type")
+	assert.Contains(t, planOutput, "ENTERPRISE_PLUS", "Should use ENTERPRISE_PLUS edition")
+
+	t.Log("Custom preset configuration validated: 16 vCPUs, 64GB RAM, 2TB disk")
+}
+
+// TestPostgreSQLExtensions - Test PostgreSQL extensions configuration
+func TestPostgreSQLExtensions(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":    "test-project",
+			"instance_name": "test-extensions",
+			"region":        "us-central1",
+			"postgresql_extensions": []interface{}{
+				"pg_stat_statements",
+				"pgcrypto",
+				"uuid-ossp",
+				"hstore",
+			},
+			"generate_permission_script": true,
+			"default_password_length": 16,
+			"use_random_suffix":          false,
+		},
+	}
+
+	planOutput := terraform.InitAndPlan(t, terraformOptions)
+
+	// Verify extensions are included in the configuration
+	assert.Contains(t, planOutput, "pg_stat_statements", "Should include pg_stat_statements extension")
+	assert.Contains(t, planOutput, "pgcrypto", "Should include pgcrypto extension")
+	assert.Contains(t, planOutput, "uuid-ossp", "Should include uuid-ossp extension")
+
+	t.Log("PostgreSQL extensions configuration validated")
+}
+
+// TestPerDatabaseExtensions - Test per-database extensions with pinned versions, schemas and CASCADE
+func TestPerDatabaseExtensions(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":            "test-project",
+			"instance_name":         "test-db-extensions",
+			"region":                "us-central1",
+			"postgresql_extensions": []string{"pgcrypto"},
+			"databases": map[string]interface{}{
+				"app_db": map[string]interface{}{
+					"schemas": map[string]interface{}{"gis": map[string]interface{}{}},
+					"extensions": map[string]interface{}{
+						"postgis":  map[string]interface{}{"version": "3.4.2", "schema": "gis", "cascade": true},
+						"pgcrypto": map[string]interface{}{"version": "1.3"},
+					},
+				},
+				"analytics": map[string]interface{}{},
+			},
+			"default_password_length": 16,
+			"use_random_suffix":       false,
+		},
+	}
+
+	planOutput := terraform.InitAndPlan(t, terraformOptions)
+
+	assert.Contains(t, planOutput, "CREATE EXTENSION IF NOT EXISTS \"postgis\" SCHEMA \"gis\" VERSION '3.4.2' CASCADE;", "Should pin the version and schema")
+	assert.Contains(t, planOutput, "ALTER EXTENSION \"postgis\" UPDATE TO '3.4.2';", "Should update the extension when the pinned version changes")
+	assert.Contains(t, planOutput, "CREATE EXTENSION IF NOT EXISTS \"pgcrypto\" VERSION '1.3';", "Should let a database override the global extension")
+	assert.Contains(t, planOutput, "\\c 'analytics'", "Should install global extensions in every database")
+
+	assert.Contains(t, planOutput, "version = \"3.4.2\"", "postgres_info should report the extensions of each database")
+
+	terraformOptions.Vars["databases"] = map[string]interface{}{
+		"app_db": map[string]interface{}{
+			"extensions": map[string]interface{}{"postgis": map[string]interface{}{"version": "3.4'; DROP"}},
+		},
+	}
+	_, err := terraform.PlanE(t, terraformOptions)
+	assert.Error(t, err, "Should reject invalid extension versions")
+
+	t.Log("Per-database extensions validated")
+}
+
+// TestExtensionAllowlistAndFlags - Test the Cloud SQL extension allowlist and the flags extensions enable
+func TestExtensionAllowlistAndFlags(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":            "test-project",
+			"instance_name":         "test-extension-flags",
+			"region":                "us-central1",
+			"postgresql_extensions": []string{"pg_stat_statements", "pgaudit"},
+			"databases": map[string]interface{}{
+				"app_db": map[string]interface{}{
+					"extensions": map[string]interface{}{"pg_cron": map[string]interface{}{}},
+				},
+			},
+			"default_password_length": 16,
+			"use_random_suffix":       false,
+		},
+	}
+
+	planOutput := terraform.InitAndPlan(t, terraformOptions)
+
+	assert.Contains(t, planOutput, "cloudsql.enable_pgaudit", "Should enable the pgaudit flag")
+	assert.Contains(t, planOutput, "cloudsql.enable_pg_cron", "Should enable the pg_cron flag for per-database extensions")
+
+	terraformOptions.Vars["postgresql_extensions"] = []string{"timescaledb"}
+	_, err := terraform.PlanE(t, terraformOptions)
+	assert.Error(t, err, "Should reject extensions Cloud SQL does not support")
+	assert.Contains(t, err.Error(), "Extensions not in the Cloud SQL allowlist")
+
+	terraformOptions.Vars["additional_allowed_extensions"] = []string{"timescaledb"}
+	_, err = terraform.PlanE(t, terraformOptions)
+	assert.NoError(t, err, "Should accept extensions added to additional_allowed_extensions")
+
+	terraformOptions.Vars["postgresql_extensions"] = []string{"pg_walinspect"}
+	terraformOptions.Vars["additional_allowed_extensions"] = []string{"pg_walinspect"}
+	terraformOptions.Vars["postgres_version"] = "POSTGRES_14"
+	_, err = terraform.PlanE(t, terraformOptions)
+	assert.Error(t, err, "Should reject extensions newer than the PostgreSQL version, even when allowed")
+	assert.Contains(t, err.Error(), "pg_walinspect (15)")
+
+	terraformOptions.Vars["postgres_version"] = "POSTGRES_15"
+	_, err = terraform.PlanE(t, terraformOptions)
+	assert.NoError(t, err, "Should accept extensions once the PostgreSQL version has them")
+
+	// Built-in extensions are checked against the Cloud SQL extensions of the configured version
+	terraformOptions.Vars["postgresql_extensions"] = []string{"pg_surgery"}
+	terraformOptions.Vars["additional_allowed_extensions"] = []string{}
+	terraformOptions.Vars["postgres_version"] = "POSTGRES_13"
+	_, err = terraform.PlanE(t, terraformOptions)
+	assert.Error(t, err, "Should reject pg_surgery before PostgreSQL 14")
+	assert.Contains(t, err.Error(), "pg_surgery (14)")
+
+	terraformOptions.Vars["postgres_version"] = "POSTGRES_14"
+	_, err = terraform.PlanE(t, terraformOptions)
+	assert.NoError(t, err, "Should accept pg_surgery on PostgreSQL 14")
+
+	t.Log("Extension allowlist and flags validated")
+}
+
+// TestCronJobs - Test pg_cron job scheduling and the flags it enables
+func TestCronJobs(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":    "test-project",
+			"instance_name": "test-cron-jobs",
+			"region":        "us-central1",
+			"databases": map[string]interface{}{
+				"app_db": map[string]interface{}{},
+			},
+			"cron_jobs": map[string]interface{}{
+				"prune-partitions": map[string]interface{}{
+					"schedule": "0 3 * * *",
+					"database": "app_db",
+					"command":  "CALL partman.run_maintenance_proc()",
+				},
+			},
+			"default_password_length": 16,
+			"use_random_suffix":       false,
+		},
+	}
+
+	planOutput := terraform.InitAndPlan(t, terraformOptions)
+
+	assert.Contains(t, planOutput, "cron.schedule_in_database('prune-partitions'", "Should schedule the job by name")
+	assert.Contains(t, planOutput, "cron.unschedule(jobid)", "Should unschedule jobs that are no longer declared")
+	assert.Contains(t, planOutput, "cloudsql.enable_pg_cron", "Should enable the pg_cron flag")
+
+	// Listing pg_cron without jobs must not write a script that unschedules jobs managed elsewhere
+	extensionOnlyOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":    "test-project",
+			"instance_name": "test-cron-extension",
+			"region":        "us-central1",
+			"databases": map[string]interface{}{
+				"app_db": map[string]interface{}{
+					"extensions": map[string]interface{}{"pg_cron": map[string]interface{}{}},
+				},
+			},
+			"default_password_length": 16,
+			"use_random_suffix":       false,
+		},
+		PlanFilePath: filepath.Join(t.TempDir(), "plan.out"),
+	}
+	plan := terraform.InitAndPlanAndShowWithStruct(t, extensionOnlyOptions)
+
+	assert.NotContains(t, plan.ResourcePlannedValuesMap, "local_file.cron_jobs_script[0]", "Should not generate the cron script without jobs")
+	terraform.RequirePlannedValuesMapKeyExists(t, plan, "google_sql_database_instance.postgres")
+	settings := plannedBlock(t, plan.ResourcePlannedValuesMap["google_sql_database_instance.postgres"].AttributeValues, "settings")
+	flags := map[string]interface{}{}
+	for _, flag := range settings["database_flags"].([]interface{}) {
+		flags[flag.(map[string]interface{})["name"].(string)] = flag.(map[string]interface{})["value"]
+	}
+	assert.Equal(t, "on", flags["cloudsql.enable_pg_cron"], "Should still enable the pg_cron flag")
+	assert.Equal(t, "app_db", flags["cron.database_name"], "Should install pg_cron in the database that lists it")
+
+	terraformOptions.Vars["cron_jobs"] = map[string]interface{}{
+		"bad-schedule": map[string]interface{}{
+			"schedule": "every night",
+			"database": "app_db",
+			"command":  "VACUUM",
+		},
+	}
+	_, err := terraform.PlanE(t, terraformOptions)
+	assert.Error(t, err, "Should reject invalid cron schedules")
+
+	terraformOptions.Vars["cron_jobs"] = map[string]interface{}{
+		"unknown-db": map[string]interface{}{
+			"schedule": "*/5 * * * *",
+			"database": "missing_db",
+			"command":  "VACUUM",
+		},
+	}
+	_, err = terraform.PlanE(t, terraformOptions)
+	assert.Error(t, err, "Should reject jobs targeting undeclared databases")
+
+	// The cron database is rendered into the generated SQL
+	terraformOptions.Vars["cron_database_name"] = "app_db'; DROP TABLE x; --"
+	_, err = terraform.PlanE(t, terraformOptions)
+	assert.Error(t, err, "Should reject invalid cron database names")
+	assert.Contains(t, err.Error(), "cron_database_name must start with a letter or underscore")
+
+	t.Log("pg_cron jobs validated")
+}
+
+// TestDatabaseSchemas - Test per-database schemas with owners and per-schema grants
+func TestDatabaseSchemas(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":    "test-project",
+			"instance_name": "test-schemas",
+			"region":        "us-central1",
+			"databases": map[string]interface{}{
+				"app_db": map[string]interface{}{
+					"schemas": map[string]interface{}{
+						"billing": map[string]interface{}{
+							"owner":  "app_user",
+							"grants": map[string]interface{}{"reporting": "readonly"},
+						},
+					},
+				},
+			},
+			"users": map[string]interface{}{
+				"app_user":  map[string]interface{}{"role": "readwrite"},
+				"reporting": map[string]interface{}{"role": "custom"},
+			},
+			"generate_permission_script": true,
+			"default_password_length":    16,
+			"use_random_suffix":          false,
+		},
+	}
+
+	planOutput := terraform.InitAndPlan(t, terraformOptions)
+
+	assert.Contains(t, planOutput, "CREATE SCHEMA IF NOT EXISTS \"billing\"", "Should create the billing schema")
+	assert.Contains(t, planOutput, "ALTER SCHEMA \"billing\" OWNER TO \"app_user\"", "Should set the schema owner")
+	assert.Contains(t, planOutput, "GRANT USAGE, CREATE ON SCHEMA \"billing\" TO \"app_db_readwrite\"", "Should apply role grants to the billing schema")
+	assert.Contains(t, planOutput, "GRANT SELECT ON ALL TABLES IN SCHEMA \"billing\" TO \"app_db_billing_readonly\"", "Should apply per-schema grants")
+	assert.Contains(t, planOutput, "GRANT \"app_db_billing_readonly\" TO \"reporting\"", "Should grant the per-schema group")
+	assert.NotContains(t, planOutput, "GRANT \"app_db_readonly\" TO \"reporting\"", "Should not grant custom users access to every schema")
+
+	t.Log("Database schemas validated: creation, ownership and per-schema grants")
+}
+
+// TestPermissionGroupNameCollisions - Test that generated group role names cannot collide
+func TestPermissionGroupNameCollisions(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":    "test-project",
+			"instance_name": "test-group-names",
+			"region":        "us-central1",
+			"databases": map[string]interface{}{
+				"app": map[string]interface{}{
+					"schemas": map[string]interface{}{
+						"x": map[string]interface{}{
+							"grants": map[string]interface{}{"reporting": "readonly"},
+						},
+					},
+				},
+				"app_x": map[string]interface{}{},
+			},
+			"users": map[string]interface{}{
+				"reporting": map[string]interface{}{"role": "custom"},
+			},
+			"default_password_length": 16,
+			"use_random_suffix":       false,
+		},
+	}
+
+	// app + schema x and database app_x both produce app_x_readonly
+	_, err := terraform.InitAndPlanE(t, terraformOptions)
+	assert.Error(t, err, "Should reject colliding group role names")
+	assert.Contains(t, err.Error(), "must be unique and must not match a user name")
+
+	// A user named like a group role would be granted to itself
+	terraformOptions.Vars["databases"] = map[string]interface{}{"app": map[string]interface{}{}}
+	terraformOptions.Vars["users"] = map[string]interface{}{
+		"app_readonly": map[string]interface{}{"role": "readonly"},
+	}
+	_, err = terraform.PlanE(t, terraformOptions)
+	assert.Error(t, err, "Should reject users named like a group role")
+
+	t.Log("Group role name collisions rejected")
+}
+
+// TestDatabaseOwnership - Test database owners and ownership transfer in the permission script
+func TestDatabaseOwnership(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":    "test-project",
+			"instance_name": "test-ownership",
+			"region":        "us-central1",
+			"databases": map[string]interface{}{
+				"app_db": map[string]interface{}{
+					"owner":               "migrator",
+					"reassign_owned_from": []interface{}{"postgres"},
+				},
+			},
+			"users": map[string]interface{}{
+				"migrator": map[string]interface{}{"role": "readwrite"},
+			},
+			"generate_permission_script": true,
+			"default_password_length":    16,
+			"use_random_suffix":          false,
+		},
+	}
+
+	planOutput := terraform.InitAndPlan(t, terraformOptions)
+
+	assert.Contains(t, planOutput, "ALTER DATABASE \"app_db\" OWNER TO \"migrator\"", "Should transfer database ownership")
+	assert.Contains(t, planOutput, "ALTER SCHEMA \"public\" OWNER TO \"migrator\"", "Should transfer the public schema")
+	assert.Contains(t, planOutput, "REASSIGN OWNED BY \"postgres\" TO \"migrator\"", "Should reassign existing objects")
+	assert.Contains(t, planOutput, "EXECUTE format('GRANT %I TO %I', role_name, current_user);", "Should take membership in the new owner first")
+	assert.Contains(t, planOutput, "EXECUTE format('REVOKE %I FROM %I', role_name, current_user);", "Should give the membership back afterwards")
+
+	// Owners must be declared users
+	terraformOptions.Vars["databases"] = map[string]interface{}{
+		"app_db": map[string]interface{}{"owner": "unknown_user"},
+	}
+	_, err := terraform.PlanE(t, terraformOptions)
+	assert.Error(t, err, "Should reject an owner that is not a declared user")
+	assert.Contains(t, err.Error(), "must be keys in var.users")
+
+	// Roles to reassign from are rendered into the script
+	terraformOptions.Vars["databases"] = map[string]interface{}{
+		"app_db": map[string]interface{}{
+			"owner":               "migrator",
+			"reassign_owned_from": []interface{}{"postgres\"; DROP ROLE x; --"},
+		},
+	}
+	_, err = terraform.PlanE(t, terraformOptions)
+	assert.Error(t, err, "Should reject invalid reassign_owned_from role names")
+	assert.Contains(t, err.Error(), "reassign_owned_from must be role names")
+
+	t.Log("Database ownership validated: owner transfer, owner and reassign_owned_from validation")
+}
+
+// TestPermissionGroupRoles - Test NOLOGIN group roles per database and user memberships
+func TestPermissionGroupRoles(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":    "test-project",
+			"instance_name": "test-groups",
+			"region":        "us-central1",
+			"databases": map[string]interface{}{
+				"app_db":    map[string]interface{}{},
+				"analytics": map[string]interface{}{},
+			},
+			"users": map[string]interface{}{
+				"app_user": map[string]interface{}{"role": "readwrite"},
+				"analyst":  map[string]interface{}{"role": "readonly"},
+			},
+			"generate_permission_script": true,
+			"default_password_length":    16,
+			"use_random_suffix":          false,
+		},
+	}
+
+	planOutput := terraform.InitAndPlan(t, terraformOptions)
+
+	for _, group := range []string{"app_db_readonly", "app_db_readwrite", "app_db_admin", "analytics_readonly"} {
+		assert.Contains(t, planOutput, fmt.Sprintf("CREATE ROLE \"%s\" NOLOGIN", group), "Should create group role %s", group)
 	}
+	assert.Contains(t, planOutput, "GRANT SELECT ON ALL TABLES IN SCHEMA \"public\" TO \"analytics_readonly\"", "Should grant privileges to the group")
+	assert.Contains(t, planOutput, "GRANT \"app_db_readwrite\" TO \"app_user\"", "Should make app_user a member of the readwrite group")
+	assert.Contains(t, planOutput, "GRANT \"analytics_readonly\" TO \"analyst\"", "Should make analyst a member of the readonly group")
+	assert.NotContains(t, planOutput, "IN SCHEMA \"public\" TO \"analyst\"", "Should not grant table privileges to users directly")
+
+	t.Log("Group roles validated: per-database groups and memberships")
 }
 
-// TestCustomPresetConfiguration - Test custom preset with specific values
-func TestCustomPresetConfiguration(t *testing.T) {
+// TestCreatorDefaultPrivileges - Test default privileges on objects created by the owner and creator roles
+func TestCreatorDefaultPrivileges(t *testing.T) {
 	t.Parallel()
 
 	terraformOptions := &terraform.Options{
 		TerraformDir: "../",
 		Vars: map[string]interface{}{
-			"project_id":         "test-project",
-			"instance_name":      "test-custom",
-			"region":             "us-central1",
-			"use_preset_config":  "custom",
-			"machine_type":       "db-custom-16-65536", // 16 vCPUs, 64GB RAM
-			"disk_size_gb":       2000,
-			"sql_edition":        "ENTERPRISE_PLUS",
-			"default_password_length": 16,
-			"use_random_suffix":  false,
+			"project_id":    "test-project",
+			"instance_name": "test-creators",
+			"region":        "us-central1",
+			"databases": map[string]interface{}{
+				"app_db": map[string]interface{}{
+					"owner":         "app_user",
+					"creator_roles": []string{"migrator"},
+				},
+			},
+			"users": map[string]interface{}{
+				"app_user": map[string]interface{}{"role": "readwrite"},
+				"migrator": map[string]interface{}{"role": "admin", "databases": []string{"app_db"}},
+				"analyst":  map[string]interface{}{"role": "readonly"},
+			},
+			"generate_permission_script": true,
+			"default_password_length":    16,
+			"use_random_suffix":          false,
 		},
 	}
 
 	planOutput := terraform.InitAndPlan(t, terraformOptions)
 
-	// Verify custom configuration
-	assert.Contains(t, planOutput, "db-custom-16-65536", "Should use custom machine type")
-	assert.Contains(t, planOutput, "ENTERPRISE_PLUS", "Should use ENTERPRISE_PLUS edition")
+	assert.Contains(t, planOutput, "ALTER DEFAULT PRIVILEGES IN SCHEMA \"public\" GRANT SELECT ON TABLES TO \"app_db_readonly\"", "Should keep default privileges for the script user")
+	assert.Contains(t, planOutput, "ALTER DEFAULT PRIVILEGES FOR ROLE \"migrator\" IN SCHEMA \"public\" GRANT SELECT ON TABLES TO \"app_db_readonly\"", "Should cover tables the migrator creates")
+	assert.Contains(t, planOutput, "ALTER DEFAULT PRIVILEGES FOR ROLE \"app_user\" IN SCHEMA \"public\" GRANT ALL ON SEQUENCES TO \"app_db_readwrite\"", "Should cover objects the owner creates")
+	assert.Contains(t, planOutput, "ALTER DEFAULT PRIVILEGES FOR ROLE \"migrator\" IN SCHEMA \"public\" REVOKE ALL ON TABLES FROM \"app_db_readonly\"", "Should revoke creator default privileges on rollback")
+	assert.Contains(t, planOutput, "GRANT \"migrator\" TO CURRENT_USER;", "Should take membership in the creator instead of skipping it")
+	assert.Contains(t, planOutput, "REVOKE \"migrator\" FROM CURRENT_USER;", "Should give the membership back afterwards")
+	assert.NotContains(t, planOutput, "Current user is not a member", "Should not skip creator default privileges")
 
-	t.Log("Custom preset configuration validated: 16 vCPUs, 64GB RAM, 2TB disk")
+	terraformOptions.Vars["databases"] = map[string]interface{}{
+		"app_db": map[string]interface{}{"creator_roles": []string{"bad role"}},
+	}
+	_, err := terraform.PlanE(t, terraformOptions)
+	assert.Error(t, err, "Should reject invalid creator role names")
+	assert.Contains(t, err.Error(), "creator_roles must be role names")
+
+	t.Log("Creator default privileges validated")
 }
 
-// TestPostgreSQLExtensions - Test PostgreSQL extensions configuration
-func TestPostgreSQLExtensions(t *testing.T) {
+// TestUserDatabaseScoping - Test per-user database lists and per-database role maps
+func TestUserDatabaseScoping(t *testing.T) {
 	t.Parallel()
 
 	terraformOptions := &terraform.Options{
 		TerraformDir: "../",
 		Vars: map[string]interface{}{
 			"project_id":    "test-project",
-			"instance_name": "test-extensions",
+			"instance_name": "test-scoping",
 			"region":        "us-central1",
-			"postgresql_extensions": []interface{}{
-				"pg_stat_statements",
-				"pgcrypto",
-				"uuid-ossp",
-				"hstore",
+			"databases": map[string]interface{}{
+				"app_db":    map[string]interface{}{},
+				"analytics": map[string]interface{}{},
+			},
+			"users": map[string]interface{}{
+				"app_user": map[string]interface{}{
+					"role":      "readonly",
+					"databases": []interface{}{"app_db"},
+				},
+				"service": map[string]interface{}{
+					"role": "custom",
+					"database_roles": map[string]interface{}{
+						"app_db":    "readwrite",
+						"analytics": "readonly",
+					},
+				},
 			},
 			"generate_permission_script": true,
-			"default_password_length": 16,
+			"default_password_length":    16,
 			"use_random_suffix":          false,
 		},
 	}
 
 	planOutput := terraform.InitAndPlan(t, terraformOptions)
 
-	// Verify extensions are included in the configuration
-	assert.Contains(t, planOutput, "pg_stat_statements", "Should include pg_stat_statements extension")
-	assert.Contains(t, planOutput, "pgcrypto", "Should include pgcrypto extension")
-	assert.Contains(t, planOutput, "uuid-ossp", "Should include uuid-ossp extension")
+	assert.Contains(t, planOutput, "GRANT \"app_db_readonly\" TO \"app_user\"", "Should grant app_user access to app_db")
+	assert.NotContains(t, planOutput, "GRANT \"analytics_readonly\" TO \"app_user\"", "Should not grant app_user access to analytics")
+	assert.Contains(t, planOutput, "GRANT \"app_db_readwrite\" TO \"service\"", "Should apply the per-database role map")
+	assert.Contains(t, planOutput, "GRANT \"analytics_readonly\" TO \"service\"", "Should apply the per-database role map")
 
-	t.Log("PostgreSQL extensions configuration validated")
+	// Scoping to an undeclared database is rejected
+	terraformOptions.Vars["users"] = map[string]interface{}{
+		"app_user": map[string]interface{}{
+			"role":      "readonly",
+			"databases": []interface{}{"missing_db"},
+		},
+	}
+	_, err := terraform.PlanE(t, terraformOptions)
+	assert.Error(t, err, "Should reject scoping to an undeclared database")
+	assert.Contains(t, err.Error(), "not in var.databases")
+
+	t.Log("User database scoping validated: database lists and role maps")
+}
+
+// TestStructuredCustomGrants - Test structured custom grants rendered into quoted GRANT statements
+func TestStructuredCustomGrants(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":    "test-project",
+			"instance_name": "test-custom-grants",
+			"region":        "us-central1",
+			"databases": map[string]interface{}{
+				"app_db": map[string]interface{}{
+					"schemas": map[string]interface{}{"billing": map[string]interface{}{}},
+				},
+			},
+			"users": map[string]interface{}{
+				"reporter": map[string]interface{}{
+					"role": "custom",
+					"custom_grants": map[string]interface{}{
+						"app_db": []map[string]interface{}{
+							{"privileges": []string{"select"}, "object_type": "table", "schema": "billing", "objects": []string{"invoices", "line-items"}, "with_grant_option": true},
+							{"privileges": []string{"USAGE"}, "object_type": "schema", "schema": "billing"},
+							{"privileges": []string{"EXECUTE"}, "object_type": "function"},
+						},
+					},
+					"raw_grants": map[string]interface{}{
+						"app_db": []string{"GRANT pg_monitor TO reporter;"},
+					},
+				},
+			},
+			"generate_permission_script": true,
+			"default_password_length":    16,
+			"use_random_suffix":          false,
+		},
+	}
+
+	planOutput := terraform.InitAndPlan(t, terraformOptions)
+
+	assert.Contains(t, planOutput, "GRANT SELECT ON TABLE \"billing\".\"invoices\", \"billing\".\"line-items\" TO \"reporter\" WITH GRANT OPTION;", "Should render grants on listed objects")
+	assert.Contains(t, planOutput, "GRANT USAGE ON SCHEMA \"billing\" TO \"reporter\";", "Should render schema grants")
+	assert.Contains(t, planOutput, "GRANT EXECUTE ON ALL FUNCTIONS IN SCHEMA \"public\" TO \"reporter\";", "Should render grants on every object of the type")
+	assert.Contains(t, planOutput, "RAISE WARNING 'Schema % does not exist, skipping custom grants for %', 'billing', 'reporter';", "Should skip grants in missing schemas")
+	assert.Contains(t, planOutput, "RAISE WARNING 'Role % does not exist, skipping its custom grants', 'reporter';", "Should skip grants for missing roles")
+	assert.Contains(t, planOutput, "GRANT pg_monitor TO reporter;", "Should run raw grants verbatim")
+	assert.Contains(t, planOutput, "REVOKE USAGE ON SCHEMA \"billing\" FROM \"reporter\";", "Should revoke structured grants on rollback")
+
+	terraformOptions.Vars["users"] = map[string]interface{}{
+		"reporter": map[string]interface{}{
+			"role": "custom",
+			"custom_grants": map[string]interface{}{
+				"app_db": []map[string]interface{}{
+					{"privileges": []string{"EXECUTE"}, "object_type": "table"},
+				},
+			},
+		},
+	}
+	_, err := terraform.PlanE(t, terraformOptions)
+	assert.Error(t, err, "Should reject a privilege that does not apply to the object type")
+	assert.Contains(t, err.Error(), "custom_grants object_type must be table, sequence, function or schema")
+
+	terraformOptions.Vars["users"] = map[string]interface{}{
+		"reporter": map[string]interface{}{
+			"role": "custom",
+			"custom_grants": map[string]interface{}{
+				"app_db": []map[string]interface{}{
+					{"privileges": []string{"SELECT"}, "object_type": "table", "objects": []string{"invoices; DROP TABLE invoices"}},
+				},
+			},
+		},
+	}
+	_, err = terraform.PlanE(t, terraformOptions)
+	assert.Error(t, err, "Should reject object names outside the naming rules")
+
+	t.Log("Structured custom grants validated")
+}
+
+// TestDeprovisionAndRollbackScripts - Test deprovision script for removed users and the rollback script
+func TestDeprovisionAndRollbackScripts(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":    "test-project",
+			"instance_name": "test-deprovision",
+			"region":        "us-central1",
+			"databases": map[string]interface{}{
+				"app_db": map[string]interface{}{"owner": "app_user"},
+			},
+			"users": map[string]interface{}{
+				"app_user": map[string]interface{}{"role": "readwrite"},
+			},
+			"deprovisioned_users": map[string]interface{}{
+				"former_user": map[string]interface{}{},
+			},
+			"generate_permission_script": true,
+			"default_password_length":    16,
+			"use_random_suffix":          false,
+		},
+	}
+
+	planOutput := terraform.InitAndPlan(t, terraformOptions)
+
+	assert.Contains(t, planOutput, "deprovision_postgres_users.sql", "Should write the deprovision script")
+	assert.Contains(t, planOutput, "REASSIGN OWNED BY \"former_user\" TO \"app_user\"", "Should reassign objects to the database owner")
+	assert.Contains(t, planOutput, "DROP OWNED BY \"former_user\"", "Should drop remaining objects")
+	assert.Contains(t, planOutput, "WHERE rolname IN ('former_user', 'app_user')", "Should take membership in both roles before reassigning")
+	assert.Contains(t, planOutput, "REVOKE \"app_db_readwrite\" FROM \"former_user\"", "Should revoke group memberships")
+	assert.Contains(t, planOutput, "rollback_postgres_permissions.sql", "Should write the rollback script")
+	assert.Contains(t, planOutput, "REVOKE \"app_db_readwrite\" FROM \"app_user\"", "Should revoke current memberships on rollback")
+	assert.Contains(t, planOutput, "DROP ROLE IF EXISTS \"app_db_readwrite\"", "Should drop group roles on rollback")
+
+	// Deprovisioned names are rendered into SQL comments and DO blocks
+	for _, deprovisioned := range []map[string]interface{}{
+		{"former_user\n$$; DROP ROLE x; --": map[string]interface{}{}},
+		{"former_user": map[string]interface{}{"reassign_to": "app_user$$"}},
+	} {
+		terraformOptions.Vars["deprovisioned_users"] = deprovisioned
+		_, err := terraform.PlanE(t, terraformOptions)
+		assert.Error(t, err, "Should reject invalid deprovisioned role names")
+		assert.Contains(t, err.Error(), "Deprovisioned user names and reassign_to must be role names")
+	}
+
+	// Objects cannot be reassigned to the user being removed
+	terraformOptions.Vars["deprovisioned_users"] = map[string]interface{}{
+		"former_user": map[string]interface{}{"reassign_to": "former_user"},
+	}
+	_, err := terraform.PlanE(t, terraformOptions)
+	assert.Error(t, err, "Should reject reassigning to the deprovisioned user")
+	assert.Contains(t, err.Error(), "reassign_to must name another role than the deprovisioned user")
+
+	// A database owned by the user being removed falls back to postgres
+	terraformOptions.Vars["databases"] = map[string]interface{}{
+		"app_db": map[string]interface{}{"owner": "former_user"},
+	}
+	terraformOptions.Vars["users"] = map[string]interface{}{
+		"app_user":    map[string]interface{}{"role": "readwrite"},
+		"former_user": map[string]interface{}{"role": "readwrite"},
+	}
+	terraformOptions.Vars["deprovisioned_users"] = map[string]interface{}{"former_user": map[string]interface{}{}}
+	planOutput = terraform.Plan(t, terraformOptions)
+	assert.Contains(t, planOutput, "REASSIGN OWNED BY \"former_user\" TO \"postgres\"", "Should not reassign objects to the user being removed")
+
+	t.Log("Deprovision and rollback scripts validated")
+}
+
+// TestIdempotentPermissionScript - Test transactional, guarded and re-runnable generated SQL
+func TestIdempotentPermissionScript(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":    "test-project",
+			"instance_name": "test-idempotent",
+			"region":        "us-central1",
+			"databases": map[string]interface{}{
+				"app_db": map[string]interface{}{},
+			},
+			"users": map[string]interface{}{
+				"app_admin": map[string]interface{}{"role": "admin"},
+			},
+			"generate_permission_script": true,
+			"default_password_length":    16,
+			"use_random_suffix":          false,
+		},
+	}
+
+	planOutput := terraform.InitAndPlan(t, terraformOptions)
+
+	assert.Contains(t, planOutput, "\\set ON_ERROR_STOP on", "Should stop on the first error")
+	assert.Contains(t, planOutput, "BEGIN;", "Should open a transaction per database")
+	assert.Contains(t, planOutput, "COMMIT;", "Should commit each database section")
+	assert.Contains(t, planOutput, "SELECT 1 FROM pg_namespace WHERE nspname = 'public'", "Should guard grants on schema existence")
+	assert.Contains(t, planOutput, "SELECT 1 FROM pg_roles WHERE rolname = 'app_admin'", "Should guard ALTER USER on role existence")
+	assert.Contains(t, planOutput, "\\echo 'Ownership, schemas and group privileges applied in database' 'app_db'", "Should print a summary")
+
+	t.Log("Permission script validated: ON_ERROR_STOP, transactions and guards")
+}
+
+// TestNativePostgreSQLProvider - Apply modules/postgresql-native through the fixture against a local PostgreSQL
+// CI runs it against a postgres service container; locally start one with:
+// docker run -d -p 5432:5432 -e POSTGRES_PASSWORD=postgres postgres:16
+// and set POSTGRES_TEST_HOST=localhost POSTGRES_TEST_PASSWORD=postgres
+func TestNativePostgreSQLProvider(t *testing.T) {
+	host := os.Getenv("POSTGRES_TEST_HOST")
+	if host == "" {
+		t.Skip("POSTGRES_TEST_HOST is not set; skipping test against a local PostgreSQL")
+	}
+
+	username := os.Getenv("POSTGRES_TEST_USER")
+	if username == "" {
+		username = "postgres"
+	}
+
+	// PostgreSQL 14 also covers the public schema grant the module adds before version 15
+	for _, majorVersion := range []int{16, 14} {
+		terraformOptions := &terraform.Options{
+			TerraformDir: "fixtures/postgresql-native",
+			Vars: map[string]interface{}{
+				"host":          host,
+				"username":      username,
+				"password":      os.Getenv("POSTGRES_TEST_PASSWORD"),
+				"major_version": majorVersion,
+				"login_roles":   []string{"native_test_user", "native_test_creator"},
+				"databases": map[string]interface{}{
+					"postgres": map[string]interface{}{
+						"name": "postgres",
+						"schemas": map[string]interface{}{
+							"public":    map[string]interface{}{},
+							"reporting": map[string]interface{}{},
+						},
+						// The connecting role is a creator too, which schema_objects already covers
+						"creators": []string{username, "native_test_creator"},
+						"extensions": map[string]interface{}{
+							"pgcrypto": map[string]interface{}{},
+						},
+					},
+				},
+				"groups": map[string]interface{}{
+					"postgres": []interface{}{
+						map[string]interface{}{"name": "native_test_readonly", "level": "readonly", "schemas": []interface{}{"public", "reporting"}},
+						map[string]interface{}{"name": "native_test_readwrite", "level": "readwrite", "schemas": []interface{}{"public", "reporting"}},
+					},
+				},
+				"memberships": map[string]interface{}{
+					"native_test_user": []string{"native_test_readonly"},
+				},
+				"grants": []interface{}{
+					map[string]interface{}{
+						"role": "native_test_user", "database": "postgres", "schema": "reporting", "object_type": "schema",
+						"objects": []string{}, "privileges": []string{"USAGE"}, "with_grant_option": false,
+					},
+					map[string]interface{}{
+						"role": "native_test_user", "database": "postgres", "schema": "public", "object_type": "table",
+						"objects": []string{}, "privileges": []string{"SELECT"}, "with_grant_option": false,
+					},
+				},
+			},
+		}
+
+		// The second version applies over the first; the deferred destroys run once both are checked
+		defer terraform.Destroy(t, terraformOptions)
+		terraform.InitAndApply(t, terraformOptions)
+
+		groupRoles := terraform.OutputList(t, terraformOptions, "group_roles")
+		assert.ElementsMatch(t, []string{"native_test_readonly", "native_test_readwrite"}, groupRoles, "Should create the group roles")
+		assert.Contains(t, terraform.OutputList(t, terraformOptions, "schemas"), "postgres/reporting", "Should create the reporting schema")
+
+		// A second plan must be empty, so drift would be the only source of changes
+		exitCode := terraform.PlanExitCode(t, terraformOptions)
+		assert.Equal(t, 0, exitCode, "Should have no changes after apply on PostgreSQL %d settings", majorVersion)
+	}
+
+	t.Log("Native postgresql provider validated against a local PostgreSQL")
+}
+
+// TestPermissionModelOutput - Test the permission model output that modules/postgresql-native takes
+func TestPermissionModelOutput(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":    "test-project",
+			"instance_name": "test-permission-model",
+			"region":        "us-central1",
+			"databases": map[string]interface{}{
+				"app_db": map[string]interface{}{"owner": "app_user"},
+			},
+			"users": map[string]interface{}{
+				"app_user": map[string]interface{}{"role": "readwrite"},
+				"admin":    map[string]interface{}{"role": "admin"},
+			},
+			"default_password_length": 16,
+			"use_random_suffix":       false,
+		},
+		PlanFilePath: filepath.Join(t.TempDir(), "plan.out"),
+	}
+
+	plan := terraform.InitAndPlanAndShowWithStruct(t, terraformOptions)
+
+	output, ok := plan.RawPlan.PlannedValues.Outputs["permission_model"]
+	require.True(t, ok, "Should plan the permission_model output")
+	model := output.Value.(map[string]interface{})
+
+	memberships := model["memberships"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"app_db_readwrite"}, memberships["app_user"], "Should make app_user a member of the readwrite group")
+	assert.Equal(t, []interface{}{"admin"}, model["admins"], "Should list unscoped admins")
+
+	databases := model["databases"].(map[string]interface{})
+	appDB := databases["app_db"].(map[string]interface{})
+	assert.Equal(t, "app_db", appDB["name"])
+	assert.Equal(t, []interface{}{"app_user"}, appDB["creators"], "Should pass the owner as a creator role")
+
+	t.Log("Permission model output validated")
+}
+
+// TestQuotedSQLIdentifiers - Test that names needing quotes are only rendered quoted in the generated scripts
+func TestQuotedSQLIdentifiers(t *testing.T) {
+	t.Parallel()
+
+	// Valid names that break or change meaning when unquoted: hyphens, dots and upper case
+	names := []string{"App-db", "Sales-2024", "Order-Items", "App.User", "Ops-Admin", "Report.Reader", "Etl.Loader", "Legacy-Owner", "Former.User", "Ext-Schema", "Nightly.Job", "Cron.Runner", "uuid-ossp"}
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":    "test-project",
+			"instance_name": "test-quoting",
+			"region":        "us-central1",
+			"databases": map[string]interface{}{
+				"App-db": map[string]interface{}{
+					"owner":               "App.User",
+					"reassign_owned_from": []string{"Legacy-Owner"},
+					"creator_roles":       []string{"Etl.Loader"},
+					"extensions": map[string]interface{}{
+						"uuid-ossp": map[string]interface{}{"schema": "Ext-Schema"},
+						"pg_cron":   map[string]interface{}{},
+					},
+					"schemas": map[string]interface{}{
+						"Sales-2024": map[string]interface{}{
+							"owner":  "App.User",
+							"grants": map[string]interface{}{"Report.Reader": "readonly"},
+						},
+					},
+				},
+			},
+			"users": map[string]interface{}{
+				"App.User":  map[string]interface{}{"role": "readwrite"},
+				"Ops-Admin": map[string]interface{}{"role": "admin"},
+				"Report.Reader": map[string]interface{}{
+					"role": "custom",
+					"custom_grants": map[string]interface{}{
+						"App-db": []interface{}{
+							map[string]interface{}{"privileges": []string{"USAGE"}, "object_type": "schema", "schema": "Sales-2024"},
+							map[string]interface{}{"privileges": []string{"SELECT"}, "object_type": "table", "schema": "Sales-2024", "objects": []string{"Order-Items"}},
+						},
+					},
+				},
+			},
+			"deprovisioned_users": map[string]interface{}{
+				"Former.User": map[string]interface{}{"reassign_to": "App.User"},
+			},
+			"cron_database_name": "App-db",
+			"cron_jobs": map[string]interface{}{
+				"Nightly.Job": map[string]interface{}{"schedule": "0 3 * * *", "database": "App-db", "command": "VACUUM", "username": "Cron.Runner"},
+			},
+			"generate_permission_script": true,
+			"default_password_length":    16,
+			"use_random_suffix":          false,
+		},
+		PlanFilePath: filepath.Join(t.TempDir(), "plan.out"),
+	}
+
+	plan := terraform.InitAndPlanAndShowWithStruct(t, terraformOptions)
+
+	// Drop comment lines, quoted identifiers and string literals; none of the names may be left over
+	comments := regexp.MustCompile(`(?m)^\s*--.*$`)
+	quoted := regexp.MustCompile(`"(?:[^"]|"")*"|'(?:[^']|'')*'`)
+	scripts := 0
+	for address, resource := range plan.ResourcePlannedValuesMap {
+		filename, _ := resource.AttributeValues["filename"].(string)
+		if resource.Type != "local_file" || !strings.HasSuffix(filename, ".sql") {
+			continue
+		}
+		scripts++
+		content, ok := resource.AttributeValues["content"].(string)
+		require.True(t, ok, "%s should render during plan", address)
+		unquoted := quoted.ReplaceAllString(comments.ReplaceAllString(content, ""), "")
+		for _, name := range names {
+			assert.NotContains(t, unquoted, name, "%s renders %s unquoted:\n%s", address, name, content)
+		}
+	}
+	assert.Equal(t, 5, scripts, "Should render the permission, rollback, deprovision, extensions and cron scripts")
+
+	permissions := plan.ResourcePlannedValuesMap["local_file.permission_script[0]"].AttributeValues["content"].(string)
+	assert.Contains(t, permissions, "\\c 'App-db'", "Should quote the database name in psql meta-commands")
+	assert.Contains(t, permissions, "CREATE ROLE \"App-db_readwrite\" NOLOGIN", "Should quote group role names")
+	assert.Contains(t, permissions, "GRANT \"App-db_readwrite\" TO \"App.User\"", "Should quote user names")
+	assert.Contains(t, permissions, "WHERE rolname = 'App.User'", "Should render user names as string literals in checks")
+	assert.Contains(t, permissions, "ON TABLE \"Sales-2024\".\"Order-Items\"", "Should quote both parts of qualified names")
+
+	t.Log("SQL identifier quoting validated")
+}
+
+// TestInvalidSQLNames - Test that database and user names outside the Cloud SQL naming rules are rejected
+func TestInvalidSQLNames(t *testing.T) {
+	t.Parallel()
+
+	terraformOptions := &terraform.Options{
+		TerraformDir: "../",
+		Vars: map[string]interface{}{
+			"project_id":    "test-project",
+			"instance_name": "test-names",
+			"region":        "us-central1",
+			"databases": map[string]interface{}{
+				"app db": map[string]interface{}{},
+			},
+			"default_password_length": 16,
+			"use_random_suffix":       false,
+		},
+	}
+
+	terraform.Init(t, terraformOptions)
+	_, err := terraform.PlanE(t, terraformOptions)
+	assert.Error(t, err, "Should reject a database name containing a space")
+	assert.Contains(t, err.Error(), "Database names must start with a letter or underscore")
+
+	terraformOptions.Vars["databases"] = map[string]interface{}{}
+	terraformOptions.Vars["users"] = map[string]interface{}{
+		"o'brien": map[string]interface{}{"role": "readonly"},
+	}
+	_, err = terraform.PlanE(t, terraformOptions)
+	assert.Error(t, err, "Should reject a user name containing a quote")
+	assert.Contains(t, err.Error(), "User names must start with a letter or underscore")
+
+	terraformOptions.Vars["users"] = map[string]interface{}{
+		"pg_monitor": map[string]interface{}{"role": "readonly"},
+	}
+	_, err = terraform.PlanE(t, terraformOptions)
+	assert.Error(t, err, "Should reject a reserved pg_ prefix")
+
+	t.Log("SQL name validation works")
+}
+
+// Helper function to get the single nested block of a planned resource
+func plannedBlock(t *testing.T, values map[string]interface{}, name string) map[string]interface{} {
+	blocks, ok := values[name].([]interface{})
+	require.True(t, ok && len(blocks) == 1, "Should plan exactly one %s block", name)
+	return blocks[0].(map[string]interface{})
 }
 
 // Helper function to parse JSON output from terraform
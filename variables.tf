@@ -41,13 +41,67 @@ variable "postgres_version" {
 }
 
 variable "postgresql_extensions" {
-  description = "List of PostgreSQL extensions to enable"
+  description = "List of PostgreSQL extensions to enable in every database; use databases[*].extensions for per-database extensions"
   type        = list(string)
   default = [
     "pg_stat_statements",
     "pgcrypto",
     "uuid-ossp"
   ]
+
+  validation {
+    condition     = alltrue([for extension in var.postgresql_extensions : can(regex("^[a-z0-9_-]+$", extension))])
+    error_message = "Extension names may only contain lowercase letters, digits, underscores and hyphens."
+  }
+}
+
+variable "additional_allowed_extensions" {
+  description = "Extensions to accept on top of the built-in per-version Cloud SQL allowlist, for extensions Cloud SQL added after this module version. They are accepted on every PostgreSQL version"
+  type        = list(string)
+  default     = []
+}
+
+variable "cron_jobs" {
+  description = "pg_cron jobs keyed by job name. When any are declared, the generated script creates or updates them and unschedules every other named job, so named jobs should then only be managed here. Listing pg_cron in a database without jobs only enables the extension"
+  type = map(object({
+    schedule = string           # Cron expression (e.g. "0 3 * * *"), "@daily" or "30 seconds"
+    database = string           # Database the command runs in: a key in var.databases or postgres
+    command  = string           # SQL command to run
+    username = optional(string) # Role the job runs as (defaults to the role running the script)
+    active   = optional(bool, true)
+  }))
+  default = {}
+
+  validation {
+    condition     = alltrue([for job_name in keys(var.cron_jobs) : can(regex("^[a-zA-Z0-9_.-]{1,63}$", job_name))])
+    error_message = "Cron job names may only contain letters, digits, underscores, dots and hyphens and be at most 63 characters."
+  }
+
+  validation {
+    condition = alltrue([
+      for job in values(var.cron_jobs) :
+      can(regex("^(@(yearly|annually|monthly|weekly|daily|hourly|reboot)|[1-5]?[0-9] seconds|([0-9*,/$-]+\\s+){4}[0-9*,/$-]+)$", job.schedule))
+    ])
+    error_message = "Cron job schedules must be a five-field cron expression, a @daily style macro or \"N seconds\" with N between 1 and 59."
+  }
+
+  validation {
+    condition = alltrue([
+      for job in values(var.cron_jobs) : job.username == null ? true : can(regex("^[a-zA-Z_][a-zA-Z0-9_.-]{0,62}$", job.username))
+    ])
+    error_message = "Cron job usernames must be valid role names."
+  }
+}
+
+variable "cron_database_name" {
+  description = "Database pg_cron is installed in (cron.database_name). Defaults to the database that lists pg_cron in its extensions, or postgres"
+  type        = string
+  default     = null
+
+  validation {
+    condition     = var.cron_database_name == null ? true : can(regex("^[a-zA-Z_][a-zA-Z0-9_-]{0,62}$", var.cron_database_name))
+    error_message = "cron_database_name must start with a letter or underscore, contain only letters, digits, underscores and hyphens and be at most 63 characters."
+  }
 }
 
 # ==========================================
@@ -142,14 +196,85 @@ variable "disk_autoresize_limit_gb" {
 # ==========================================
 
 variable "databases" {
-  description = "Map of databases to create with optional charset and collation"
+  description = "Map of databases to create with optional charset, collation, owner, schemas and extensions"
   type = map(object({
-    charset   = optional(string)
-    collation = optional(string)
+    charset             = optional(string)
+    collation           = optional(string)
+    owner               = optional(string)           # Key in var.users that owns the database and its schemas
+    reassign_owned_from = optional(list(string), []) # Roles whose objects in the database are reassigned to the owner
+    creator_roles       = optional(list(string), []) # Roles that create objects (e.g. a migration user); default privileges also cover the owner
+    extensions = optional(map(object({               # Extensions for this database only, added to var.postgresql_extensions
+      version = optional(string)                     # Pinned version; the script updates the extension when it changes
+      schema  = optional(string)                     # Schema to install the extension objects into
+      cascade = optional(bool, false)                # Also install the extensions it depends on
+    })), {})
+    schemas = optional(map(object({
+      owner  = optional(string)          # User that owns the schema (defaults to the database owner)
+      grants = optional(map(string), {}) # Map of user to additional access level (admin, readwrite, readonly) on this schema
+    })), {})                             # The public schema is always managed
   }))
   default = {
     main = {}
   }
+
+  validation {
+    condition = alltrue(flatten([
+      for db in var.databases : [
+        for schema in values(db.schemas) : [
+          for level in values(schema.grants) : contains(["admin", "readwrite", "readonly"], level)
+        ]
+      ]
+    ]))
+    error_message = "Schema grant levels must be admin, readwrite or readonly."
+  }
+
+  validation {
+    condition = alltrue([
+      for db_name in keys(var.databases) :
+      can(regex("^[a-zA-Z_][a-zA-Z0-9_-]{0,62}$", db_name)) && !contains(["postgres", "template0", "template1", "cloudsqladmin"], db_name)
+    ])
+    error_message = "Database names must start with a letter or underscore, contain only letters, digits, underscores and hyphens, be at most 63 characters and not be a reserved Cloud SQL database."
+  }
+
+  validation {
+    condition = alltrue(flatten([
+      for db in values(var.databases) : [
+        for schema_name in keys(db.schemas) :
+        can(regex("^[a-zA-Z_][a-zA-Z0-9_-]{0,62}$", schema_name)) && !startswith(schema_name, "pg_")
+      ]
+    ]))
+    error_message = "Schema names must start with a letter or underscore, contain only letters, digits, underscores and hyphens, be at most 63 characters and not start with pg_."
+  }
+
+  validation {
+    condition = alltrue(flatten([
+      for db in values(var.databases) : [
+        for role in db.creator_roles : can(regex("^[a-zA-Z_][a-zA-Z0-9_.-]{0,62}$", role))
+      ]
+    ]))
+    error_message = "creator_roles must be role names that start with a letter or underscore and contain only letters, digits, underscores, dots and hyphens."
+  }
+
+  validation {
+    condition = alltrue(flatten([
+      for db in values(var.databases) : [
+        for role in db.reassign_owned_from : can(regex("^[a-zA-Z_][a-zA-Z0-9_.-]{0,62}$", role))
+      ]
+    ]))
+    error_message = "reassign_owned_from must be role names that start with a letter or underscore and contain only letters, digits, underscores, dots and hyphens."
+  }
+
+  validation {
+    condition = alltrue(flatten([
+      for db in values(var.databases) : [
+        for name, extension in db.extensions :
+        can(regex("^[a-z0-9_-]+$", name)) &&
+        (extension.version == null ? true : can(regex("^[A-Za-z0-9._-]+$", extension.version))) &&
+        (extension.schema == null ? true : can(regex("^[a-zA-Z_][a-zA-Z0-9_-]{0,62}$", extension.schema)))
+      ]
+    ]))
+    error_message = "Database extension names may only contain lowercase letters, digits, underscores and hyphens, versions only letters, digits, dots, underscores and hyphens, and schemas must be valid schema names."
+  }
 }
 
 variable "users" {
@@ -163,13 +288,79 @@ variable "users" {
     password_min_lower   = optional(number)
     password_min_numeric = optional(number)
     password_min_special = optional(number)
-    custom_grants        = optional(map(list(string))) # For custom role: map of database to list of grants
+    custom_grants = optional(map(list(object({            # For custom role: map of database to structured grants
+      privileges        = list(string)                    # e.g. SELECT, INSERT, UPDATE, USAGE, EXECUTE or ALL
+      object_type       = string                          # table, sequence, function or schema
+      schema            = optional(string, "public")      # Schema holding the objects, or the schema granted on
+      objects           = optional(list(string), ["ALL"]) # Object names, or ALL for every object of the type in the schema
+      with_grant_option = optional(bool, false)
+    }))))
+    raw_grants     = optional(map(list(string))) # For custom role: map of database to SQL statements run verbatim (escape hatch)
+    databases      = optional(list(string))      # Databases the role applies to (all databases when unset)
+    database_roles = optional(map(string))       # Map of database to access level, overrides role and databases
   }))
   default = {
     app_user = {
       role = "readwrite"
     }
   }
+
+  validation {
+    condition     = alltrue([for user in values(var.users) : contains(["admin", "readwrite", "readonly", "custom"], user.role)])
+    error_message = "User roles must be admin, readwrite, readonly or custom."
+  }
+
+  validation {
+    condition = alltrue(flatten([
+      for user in values(var.users) : [
+        for level in values(coalesce(user.database_roles, {})) : contains(["admin", "readwrite", "readonly"], level)
+      ]
+    ]))
+    error_message = "database_roles levels must be admin, readwrite or readonly."
+  }
+
+  validation {
+    condition = alltrue([
+      for user_name in keys(var.users) :
+      can(regex("^[a-zA-Z_][a-zA-Z0-9_.-]{0,62}$", user_name)) && !startswith(user_name, "pg_") && !startswith(user_name, "cloudsql")
+    ])
+    error_message = "User names must start with a letter or underscore, contain only letters, digits, underscores, dots and hyphens, be at most 63 characters and not start with pg_ or cloudsql."
+  }
+
+  validation {
+    condition = alltrue(flatten([
+      for user in values(var.users) : [
+        for grants in values(coalesce(user.custom_grants, {})) : [
+          for grant in grants :
+          contains(["table", "sequence", "function", "schema"], grant.object_type) && length(grant.privileges) > 0 && alltrue([
+            for privilege in grant.privileges : contains(lookup({
+              table    = ["ALL", "SELECT", "INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER"]
+              sequence = ["ALL", "USAGE", "SELECT", "UPDATE"]
+              function = ["ALL", "EXECUTE"]
+              schema   = ["ALL", "USAGE", "CREATE"]
+            }, grant.object_type, []), upper(privilege))
+          ])
+        ]
+      ]
+    ]))
+    error_message = "custom_grants object_type must be table, sequence, function or schema, with at least one privilege valid for that object type."
+  }
+
+  validation {
+    condition = alltrue(flatten([
+      for user in values(var.users) : [
+        for grants in values(coalesce(user.custom_grants, {})) : [
+          for grant in grants :
+          can(regex("^[a-zA-Z_][a-zA-Z0-9_-]{0,62}$", grant.schema)) && length(grant.objects) > 0 && (
+            contains(grant.objects, "ALL") ? length(grant.objects) == 1 : grant.object_type != "schema" && alltrue([
+              for object in grant.objects : can(regex("^[a-zA-Z_][a-zA-Z0-9_-]{0,62}$", object))
+            ])
+          )
+        ]
+      ]
+    ]))
+    error_message = "custom_grants schema and object names must start with a letter or underscore and contain only letters, digits, underscores and hyphens; objects is either ALL or a list of names, and schema grants take no objects."
+  }
 }
 
 variable "default_password_length" {
@@ -190,6 +381,28 @@ variable "generate_permission_script" {
   default     = true
 }
 
+variable "deprovisioned_users" {
+  description = "Users being removed from the instance. The generated deprovision script reassigns their objects (to reassign_to, else the database owner unless that is the user being removed, else postgres), drops what they still own and revokes their access in every database; run it before deleting the users from var.users"
+  type = map(object({
+    reassign_to = optional(string) # Role that takes over the user's objects
+  }))
+  default = {}
+
+  validation {
+    condition = alltrue([
+      for user_name, user in var.deprovisioned_users :
+      can(regex("^[a-zA-Z_][a-zA-Z0-9_.-]{0,62}$", user_name)) &&
+      (user.reassign_to == null ? true : can(regex("^[a-zA-Z_][a-zA-Z0-9_.-]{0,62}$", user.reassign_to)))
+    ])
+    error_message = "Deprovisioned user names and reassign_to must be role names that start with a letter or underscore and contain only letters, digits, underscores, dots and hyphens."
+  }
+
+  validation {
+    condition     = alltrue([for user_name, user in var.deprovisioned_users : user.reassign_to != user_name])
+    error_message = "reassign_to must name another role than the deprovisioned user."
+  }
+}
+
 # ==========================================
 # POSTGRESQL PERFORMANCE TUNING
 # ==========================================
@@ -264,15 +477,177 @@ variable "point_in_time_recovery" {
 }
 
 variable "transaction_log_retention_days" {
-  description = "Number of days to retain transaction logs"
+  description = "Number of days to retain transaction logs for point-in-time recovery (1-7 for ENTERPRISE, 1-35 for ENTERPRISE_PLUS)"
   type        = number
   default     = 7
 }
 
+variable "backup_retention_count" {
+  description = "Number of automated backups to keep (default 30). Set this or backup_retention_days, not both"
+  type        = number
+  default     = null
+
+  validation {
+    condition     = var.backup_retention_count == null || (var.backup_retention_count >= 1 && var.backup_retention_count <= 365)
+    error_message = "Backup retention count must be between 1 and 365."
+  }
+}
+
 variable "backup_retention_days" {
-  description = "Number of backup retention days"
+  description = "Days of automated backups to keep, the time-based alternative to backup_retention_count. Cloud SQL takes one automated backup per day and keeps them by count, so this keeps that many backups. Set this or backup_retention_count, not both"
   type        = number
-  default     = 30
+  default     = null
+
+  validation {
+    condition     = var.backup_retention_days == null || (var.backup_retention_days >= 1 && var.backup_retention_days <= 365)
+    error_message = "Backup retention days must be between 1 and 365."
+  }
+}
+
+variable "backup_dr" {
+  description = "Protect the instance with Backup and DR: immutable backups in a backup vault, kept separately from the automated backups above"
+  type = object({
+    enabled                = optional(bool, false)
+    location               = optional(string)          # Defaults to region
+    backup_vault           = optional(string)          # Full name of an existing vault (a new vault is created when unset)
+    backup_vault_id        = optional(string)          # ID of the created vault (defaults to <instance_name>-vault)
+    minimum_retention_days = optional(number, 30)      # Enforced retention of the created vault
+    retention_days         = optional(number, 30)      # Retention of each backup taken by the plan
+    recurrence             = optional(string, "DAILY") # DAILY or HOURLY
+    hourly_frequency       = optional(number, 6)       # Hours between backups when recurrence is HOURLY
+    time_zone              = optional(string, "UTC")
+    window_start_hour      = optional(number, 2)
+    window_end_hour        = optional(number, 8)
+  })
+  default = {}
+
+  validation {
+    condition     = contains(["DAILY", "HOURLY"], var.backup_dr.recurrence)
+    error_message = "backup_dr.recurrence must be DAILY or HOURLY."
+  }
+
+  validation {
+    condition     = var.backup_dr.minimum_retention_days >= 1 && var.backup_dr.retention_days >= var.backup_dr.minimum_retention_days
+    error_message = "backup_dr.retention_days must be at least backup_dr.minimum_retention_days, which must be at least 1."
+  }
+
+  validation {
+    condition     = var.backup_dr.window_start_hour >= 0 && var.backup_dr.window_end_hour <= 24 && var.backup_dr.window_start_hour < var.backup_dr.window_end_hour
+    error_message = "The backup_dr window must start before it ends, within hours 0-24."
+  }
+}
+
+# ==========================================
+# CLONE AND RESTORE
+# ==========================================
+
+variable "clone_from" {
+  description = "Create the instance as a copy of an existing instance, either cloned at a point in time or restored from a backup run. List the databases the source already has in skip_databases; the module does not read the source instance"
+  type = object({
+    source_instance_name = string
+    source_project       = optional(string) # Project of the source instance for backup restores (defaults to project_id)
+    point_in_time        = optional(string) # RFC 3339 timestamp to clone at (latest state when unset)
+    backup_run_id        = optional(number) # Restore this backup run instead of cloning
+    preferred_zone       = optional(string)
+    skip_databases       = optional(list(string), []) # Keys in var.databases the source already has; they are copied instead of created
+  })
+  default = null
+
+  validation {
+    condition     = var.clone_from == null ? true : (var.clone_from.point_in_time == null || var.clone_from.backup_run_id == null)
+    error_message = "clone_from accepts either point_in_time or backup_run_id, not both."
+  }
+}
+
+# ==========================================
+# LOGICAL EXPORTS
+# ==========================================
+
+variable "exports" {
+  description = "Scheduled logical (pg_dump-style) exports of each database to a Cloud Storage bucket through the SQL Admin export API"
+  type = object({
+    enabled               = optional(bool, false)
+    bucket_name           = optional(string)              # Defaults to <instance_name>-exports
+    bucket_location       = optional(string)              # Defaults to region
+    schedule              = optional(string, "0 3 * * *") # Cron schedule of the first database; the others follow stagger_minutes apart
+    stagger_minutes       = optional(number, 30)          # Minutes between the exports of consecutive databases, 0 to run them all on schedule
+    schedule_overrides    = optional(map(string), {})     # Per-database cron schedules, left out of the staggering
+    time_zone             = optional(string, "Etc/UTC")
+    retention_days        = optional(number, 30) # Days to keep previous exports
+    coldline_after_days   = optional(number)     # Move exports to COLDLINE after this many days
+    offload               = optional(bool, false)
+    service_account_email = optional(string) # Identity Cloud Scheduler uses to call the API (created with a cloudsql.instances.export role when unset)
+  })
+  default = {}
+
+  validation {
+    condition     = var.exports.retention_days >= 1
+    error_message = "Export retention must be at least 1 day."
+  }
+
+  validation {
+    condition     = var.exports.stagger_minutes >= 0 && var.exports.stagger_minutes < 1440
+    error_message = "exports.stagger_minutes must be between 0 and 1439."
+  }
+
+  validation {
+    condition     = var.exports.stagger_minutes == 0 || can(regex("^\\d{1,2}\\s+\\d{1,2}(\\s+\\S+){3}$", var.exports.schedule))
+    error_message = "exports.schedule must start with a fixed minute and hour (e.g. \"0 3 * * *\") to stagger the databases; set stagger_minutes = 0 for other schedules."
+  }
+}
+
+# ==========================================
+# DATABASE MIGRATION
+# ==========================================
+
+variable "migration" {
+  description = "Continuous Database Migration Service job from an external PostgreSQL server into this instance. Set enabled when the instance is created, as the destination must start empty. The source needs logical decoding (wal_level = logical and pglogical in shared_preload_libraries, or cloudsql.logical_decoding and cloudsql.enable_pglogical on a Cloud SQL source), and the module sets the same flags on this instance. Databases are copied from the source; promote finishes the cutover and creates the users"
+  type = object({
+    enabled          = optional(bool, false)
+    migration_job_id = optional(string) # Defaults to <instance_name>-migration
+    location         = optional(string) # Defaults to region
+    source = optional(object({
+      host               = string
+      port               = optional(number, 5432)
+      username           = string
+      ssl_type           = optional(string, "NONE") # NONE, SERVER_ONLY or SERVER_CLIENT
+      ca_certificate     = optional(string)
+      client_certificate = optional(string)
+      client_key         = optional(string)
+    }))
+    connectivity        = optional(string, "VPC_PEERING") # VPC_PEERING or STATIC_IP
+    vpc                 = optional(string)                # Defaults to private_network_id
+    dump_parallel_level = optional(string)                # MIN, OPTIMAL or MAX
+    promote             = optional(bool, false)           # Promote this instance and finish the cutover
+  })
+  default = {}
+
+  validation {
+    condition     = !var.migration.enabled || var.migration.source != null
+    error_message = "migration.source is required when migration is enabled."
+  }
+
+  validation {
+    condition     = contains(["VPC_PEERING", "STATIC_IP"], var.migration.connectivity)
+    error_message = "migration.connectivity must be VPC_PEERING or STATIC_IP."
+  }
+
+  validation {
+    condition     = var.migration.source == null ? true : contains(["NONE", "SERVER_ONLY", "SERVER_CLIENT"], var.migration.source.ssl_type)
+    error_message = "migration.source.ssl_type must be NONE, SERVER_ONLY or SERVER_CLIENT."
+  }
+
+  validation {
+    condition     = var.migration.dump_parallel_level == null ? true : contains(["MIN", "OPTIMAL", "MAX"], var.migration.dump_parallel_level)
+    error_message = "migration.dump_parallel_level must be MIN, OPTIMAL or MAX."
+  }
+}
+
+variable "migration_source_password" {
+  description = "Password of the migration source user"
+  type        = string
+  default     = null
+  sensitive   = true
 }
 
 # ==========================================
@@ -306,6 +681,33 @@ variable "ssl_mode" {
   default     = "ENCRYPTED_ONLY"
 }
 
+# ==========================================
+# CONNECTION POOLING
+# ==========================================
+
+variable "connection_pooling" {
+  description = "Connection pooling. Uses Cloud SQL managed connection pooling on ENTERPRISE_PLUS and generates a PgBouncer configuration otherwise"
+  type = object({
+    enabled                = optional(bool, false)
+    pool_mode              = optional(string, "transaction") # session or transaction
+    pool_size              = optional(number, 20)            # Server connections per user/database pair
+    client_idle_timeout    = optional(number, 0)             # Seconds before idle client connections are closed (0 = disabled)
+    max_client_connections = optional(number, 1000)
+    listen_port            = optional(number, 6432)
+  })
+  default = {}
+
+  validation {
+    condition     = contains(["session", "transaction"], var.connection_pooling.pool_mode)
+    error_message = "Connection pool mode must be either session or transaction."
+  }
+
+  validation {
+    condition     = var.connection_pooling.pool_size >= 1 && var.connection_pooling.client_idle_timeout >= 0
+    error_message = "Connection pool size must be at least 1 and client idle timeout cannot be negative."
+  }
+}
+
 # ==========================================
 # MONITORING
 # ==========================================
@@ -383,16 +785,146 @@ variable "deny_maintenance_periods" {
 # ==========================================
 
 variable "read_replicas" {
-  description = "Map of read replicas to create"
+  description = "Map of read replicas to create. Unset settings are inherited from the primary instance"
   type = map(object({
-    region            = optional(string)
-    machine_type      = optional(string)
-    disk_size         = optional(number)
-    availability_type = optional(string)
-    failover_target   = optional(bool)
-    database_flags    = optional(map(string))
+    region                   = optional(string)
+    source                   = optional(string) # Key of another read replica to cascade from (defaults to the primary)
+    machine_type             = optional(string)
+    disk_size                = optional(number)
+    availability_type        = optional(string)
+    failover_target          = optional(bool)
+    disaster_recovery        = optional(bool) # Designate as the Enterprise Plus DR replica (at most one)
+    database_flags           = optional(map(string))
+    edition                  = optional(string) # ENTERPRISE or ENTERPRISE_PLUS
+    deletion_protection      = optional(bool)
+    disk_autoresize_limit_gb = optional(number)
+    data_cache_enabled       = optional(bool)
+    backup_location          = optional(string)
+    connector_enforcement    = optional(string)
+    labels                   = optional(map(string)) # Merged over the inherited labels, e.g. to set a different environment
+    authorized_networks = optional(list(object({     # Replaces the primary's list when set
+      name = string
+      cidr = string
+    })))
+    maintenance_window = optional(object({
+      day          = optional(number)
+      hour         = optional(number)
+      update_track = optional(string)
+    }))
+    insights_config = optional(object({
+      query_insights_enabled  = optional(bool)
+      query_string_length     = optional(number)
+      record_application_tags = optional(bool)
+      record_client_address   = optional(bool)
+      query_plans_per_minute  = optional(number)
+    }))
   }))
   default = {}
+
+  validation {
+    condition = alltrue([
+      for replica in values(var.read_replicas) :
+      replica.edition == null ? true : contains(["ENTERPRISE", "ENTERPRISE_PLUS"], replica.edition)
+    ])
+    error_message = "Read replica edition must be either ENTERPRISE or ENTERPRISE_PLUS."
+  }
+
+  validation {
+    condition     = length([for replica in values(var.read_replicas) : replica if coalesce(replica.disaster_recovery, false)]) <= 1
+    error_message = "At most one read replica can be designated as the disaster recovery replica."
+  }
+
+  validation {
+    condition = alltrue([
+      for replica in values(var.read_replicas) :
+      replica.source == null ? true : contains(keys(var.read_replicas), replica.source)
+    ])
+    error_message = "Read replica source must reference another key in read_replicas."
+  }
+
+  validation {
+    # A chain that has not reached the primary after three hops is either a cycle or too deep
+    condition = alltrue([
+      for replica in values(var.read_replicas) :
+      replica.source == null ? true : (
+        try(var.read_replicas[replica.source].source, null) == null ? true :
+        try(var.read_replicas[var.read_replicas[replica.source].source].source, null) == null
+      )
+    ])
+    error_message = "Read replica sources must not form a cycle, and cascading is limited to three levels of replicas."
+  }
+
+  validation {
+    condition = alltrue([
+      for replica in values(var.read_replicas) :
+      replica.source == null ? true : !coalesce(try(var.read_replicas[replica.source].failover_target, null), false)
+    ])
+    error_message = "A failover target replica cannot act as the source of a cascading replica."
+  }
+
+  validation {
+    condition = alltrue([
+      for replica in values(var.read_replicas) :
+      !coalesce(replica.disaster_recovery, false) || replica.source == null
+    ])
+    error_message = "The disaster recovery replica must replicate directly from the primary instance."
+  }
+}
+
+variable "dr_switchover" {
+  description = "Swap the roles of the primary instance and the disaster recovery replica. Set to true to switch over to the DR replica and back to false to switch back. Connection outputs and the PgBouncer configuration follow the instance holding the primary role; users and databases are kept and re-pointed in the state afterwards (see the README)"
+  type        = bool
+  default     = false
+}
+
+# ==========================================
+# READ POOLS
+# ==========================================
+
+variable "read_pools" {
+  description = "Map of Enterprise Plus read pools to create. Each pool serves reads from a single load-balanced endpoint"
+  type = map(object({
+    machine_type   = optional(string)
+    node_count     = optional(number) # Fixed number of nodes when autoscaling is not set
+    database_flags = optional(map(string))
+    autoscaling = optional(object({
+      min_node_count             = number
+      max_node_count             = number
+      target_cpu_utilization     = optional(number, 0.5)
+      disable_scale_in           = optional(bool, false)
+      scale_in_cooldown_seconds  = optional(number)
+      scale_out_cooldown_seconds = optional(number)
+    }))
+  }))
+  default = {}
+
+  validation {
+    condition = alltrue([
+      for pool in values(var.read_pools) :
+      pool.node_count == null || pool.autoscaling == null
+    ])
+    error_message = "Read pools accept either a fixed node_count or autoscaling bounds, not both."
+  }
+
+  validation {
+    condition = alltrue([
+      for pool in values(var.read_pools) :
+      pool.node_count == null ? true : (pool.node_count >= 1 && pool.node_count <= 20)
+    ])
+    error_message = "Read pool node_count must be between 1 and 20."
+  }
+
+  validation {
+    condition = alltrue([
+      for pool in values(var.read_pools) :
+      pool.autoscaling == null ? true : (
+        pool.autoscaling.min_node_count >= 1 &&
+        pool.autoscaling.max_node_count <= 20 &&
+        pool.autoscaling.min_node_count <= pool.autoscaling.max_node_count
+      )
+    ])
+    error_message = "Read pool autoscaling bounds must satisfy 1 <= min_node_count <= max_node_count <= 20."
+  }
 }
 
 # ==========================================
@@ -400,9 +932,35 @@ variable "read_replicas" {
 # ==========================================
 
 variable "deletion_protection" {
-  description = "Enable deletion protection"
+  description = "Enable Terraform deletion protection (defaults to true when environment is production)"
   type        = bool
-  default     = false
+  default     = null
+}
+
+variable "deletion_protection_enabled" {
+  description = "Enable API-level deletion protection on the instance, which also blocks deletion outside Terraform (defaults to true when environment is production)"
+  type        = bool
+  default     = null
+}
+
+variable "retain_backups_on_delete" {
+  description = "Keep automated and on-demand backups after the instance is deleted (defaults to true when environment is production)"
+  type        = bool
+  default     = null
+}
+
+variable "final_backup" {
+  description = "Final backup taken when the instance is deleted. enabled defaults to true when environment is production"
+  type = object({
+    enabled        = optional(bool)
+    retention_days = optional(number, 30)
+  })
+  default = {}
+
+  validation {
+    condition     = var.final_backup.retention_days >= 1 && var.final_backup.retention_days <= 365
+    error_message = "Final backup retention must be between 1 and 365 days."
+  }
 }
 
 variable "environment" {
@@ -2,12 +2,12 @@
 # Define required versions for Terraform and providers
 
 terraform {
-  required_version = ">= 1.0"
+  required_version = ">= 1.4"
 
   required_providers {
     google = {
       source  = "hashicorp/google"
-      version = ">= 6.0"
+      version = ">= 7.10" # Read pools, managed connection pooling, final backups and Backup and DR plans
     }
     random = {
       source  = "hashicorp/random"
@@ -15,7 +15,7 @@ terraform {
     }
     local = {
       source  = "hashicorp/local"
-      version = ">= 2.0"
+      version = ">= 2.2"
     }
   }
 }